@@ -1,8 +1,11 @@
 module go-Bython
 
-go 1.25
+go 1.25.0
 
-require github.com/stretchr/testify v1.11.1
+require (
+	github.com/stretchr/testify v1.11.1
+	golang.org/x/text v0.40.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"go-Bython/processor"
@@ -19,10 +21,64 @@ func main() {
 		filePattern = flag.String("pattern", "*.py", "File pattern to match (e.g., '*.py', '*.pybrace')")
 		workers     = flag.Int("workers", 4, "Number of concurrent workers for batch processing")
 		indentSize  = flag.Int("indent", 2, "Number of spaces for indentation")
+		tabs        = flag.Bool("tabs", false, "Indent the output with tabs instead of spaces")
+		tabWidth    = flag.Int("tabwidth", 8, "Number of columns a tab advances to in source indentation that is kept relative")
+		verify      = flag.Bool("verify", false, "Check that outputs differ from their sources only in layout")
+		extensions  = flag.String("ext", "", "Comma separated syntax extensions to enable ("+strings.Join(processor.ExtensionNames(), ", ")+")")
+		depsFile    = flag.String("deps", "", "Write make-style rules listing the files each output was built from")
+		semicolons  = flag.String("semicolons", "strip", "What to do with statement-terminating semicolons (strip, keep, split)")
+		strict      = flag.Bool("strict", false, "Fail instead of warning when a problem is found")
+		literals    = flag.String("literals", "keep", "Layout of multi-line list, dict and set literals (keep, canonical)")
+		commas      = flag.Bool("trailing-commas", false, "End canonically laid out literals with a trailing comma")
+		newline     = flag.String("newline", "preserve", "Line endings of the output (preserve, lf, crlf)")
+		final       = flag.String("final-newline", "always", "Whether the output ends with a newline (always, preserve, never)")
+		stripBOM    = flag.Bool("strip-bom", false, "Drop a UTF-8 byte order mark instead of copying it to the output")
+		keepMtime   = flag.Bool("preserve-mtime", false, "Give outputs the modification time of their input")
+		defines     = defineFlags{}
+		includeDirs stringList
 	)
+	flag.Var(defines, "D", "Define a flag for #!if conditions as NAME=value or NAME (repeatable)")
+	flag.Var(&includeDirs, "I", "Add a directory to search for #!include files (repeatable)")
 	flag.Parse()
 
-	p := processor.NewPythonPreprocessor(*indentSize)
+	ext, err := processor.ParseExtensions(*extensions)
+	if err != nil {
+		log.Fatal(err)
+	}
+	semicolonMode, err := processor.ParseSemicolonMode(*semicolons)
+	if err != nil {
+		log.Fatal(err)
+	}
+	literalStyle, err := processor.ParseLiteralStyle(*literals)
+	if err != nil {
+		log.Fatal(err)
+	}
+	newlineStyle, err := processor.ParseNewlineStyle(*newline)
+	if err != nil {
+		log.Fatal(err)
+	}
+	finalNewline, err := processor.ParseFinalNewline(*final)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	options := processor.Options{
+		IndentSize:      *indentSize,
+		Tabs:            *tabs,
+		TabWidth:        *tabWidth,
+		Semicolons:      semicolonMode,
+		Strict:          *strict,
+		Literals:        literalStyle,
+		TrailingCommas:  *commas,
+		Newline:         newlineStyle,
+		FinalNewline:    finalNewline,
+		StripBOM:        *stripBOM,
+		PreserveModTime: *keepMtime,
+		Extensions:      ext,
+		Defines:         defines,
+		IncludePaths:    includeDirs,
+	}
+	p := processor.NewPythonPreprocessorWithOptions(processor.WithOptions(options))
 
 	if *inputDir != "" {
 		if *outputDir == "" {
@@ -30,11 +86,20 @@ func main() {
 		}
 
 		start := time.Now()
-		fp := processor.NewFolderProcessor(*indentSize, *filePattern, *workers)
+		fp := processor.NewFolderProcessorWithOptions(*filePattern, *workers, processor.WithOptions(options))
+		fp.SetVerify(*verify)
 		if err := fp.ProcessFolder(*inputDir, *outputDir); err != nil {
 			log.Fatal(err)
 			return
 		}
+		if *depsFile != "" {
+			if err := writeDeps(*depsFile, fp.Dependencies()); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if reportDiagnostics(fp.Diagnostics()) && *verify {
+			os.Exit(1)
+		}
 		fmt.Printf("Successfully processed folder: %s -> %s in %v\n", *inputDir, *outputDir, time.Since(start))
 		return
 	}
@@ -48,10 +113,111 @@ func main() {
 	if err := p.ProcessFile(*inputFile, *outputFile); err != nil {
 		log.Fatal(err)
 	}
+	reportDiagnostics(p.Diagnostics())
+	if *depsFile != "" {
+		deps := map[string][]string{*outputFile: append([]string{*inputFile}, p.Includes()...)}
+		if err := writeDeps(*depsFile, deps); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *verify {
+		diagnostics, err := verifyFile(p, *inputFile, *outputFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if reportDiagnostics(diagnostics) {
+			os.Exit(1)
+		}
+	}
 
 	fmt.Printf("Successfully processed: %s -> %s in %v\n", *inputFile, *outputFile, time.Since(start))
 }
 
+func verifyFile(p *processor.PythonPreprocessor, inputPath, outputPath string) ([]processor.Diagnostic, error) {
+	source, err := os.ReadFile(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	output, err := os.ReadFile(outputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	diagnostics := p.Verify(processor.DecodeSource(source), processor.DecodeSource(output))
+	for i := range diagnostics {
+		if diagnostics[i].File == "" {
+			diagnostics[i].File = inputPath
+		}
+	}
+	return diagnostics, nil
+}
+
+// defineFlags collects repeated -D NAME=value flags; a bare NAME is set to 1
+type defineFlags map[string]string
+
+func (d defineFlags) String() string {
+	names := make([]string, 0, len(d))
+	for name, value := range d {
+		names = append(names, name+"="+value)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func (d defineFlags) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("missing flag name in '%s'", s)
+	}
+	if !ok {
+		value = "1"
+	}
+	d[name] = value
+	return nil
+}
+
+// stringList collects the values of a repeated flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// writeDeps writes one make rule per output, naming the files it was built
+// from as prerequisites
+func writeDeps(path string, deps map[string][]string) error {
+	outputs := make([]string, 0, len(deps))
+	for output := range deps {
+		outputs = append(outputs, output)
+	}
+	sort.Strings(outputs)
+
+	var b strings.Builder
+	for _, output := range outputs {
+		b.WriteString(output + ":")
+		for _, input := range deps[output] {
+			b.WriteString(" " + input)
+		}
+		b.WriteString("\n")
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// reportDiagnostics prints diagnostics to stderr and reports whether there were any
+func reportDiagnostics(diagnostics []processor.Diagnostic) bool {
+	for _, d := range diagnostics {
+		fmt.Fprintln(os.Stderr, d)
+	}
+	return len(diagnostics) > 0
+}
+
 func init() {
 	flag.Usage = func() {
 		fmt.Println(fmt.Sprintf("Usage: %s [options]", os.Args[0]))
@@ -62,8 +228,13 @@ func init() {
 		fmt.Println(fmt.Sprintf("  Single file:"))
 		fmt.Println(fmt.Sprintf("    go-bython -i input.py -o output.py"))
 		fmt.Println(fmt.Sprintf("    go-bython -i input.py -o output.py -indent 4"))
+		fmt.Println(fmt.Sprintf("    go-bython -i input.py -o output.py -verify"))
+		fmt.Println(fmt.Sprintf("    go-bython -i input.py -o output.py -ext hybrid"))
+		fmt.Println(fmt.Sprintf("    go-bython -i input.py -o output.py -D DEBUG -D TARGET=prod"))
 		fmt.Println(fmt.Sprintf("\n  Batch processing:"))
 		fmt.Println(fmt.Sprintf("    go-bython -d ./src -od ./output"))
 		fmt.Println(fmt.Sprintf("    go-bython -d ./src -od ./output -pattern '*.pybrace' -workers 8"))
+		fmt.Println(fmt.Sprintf("    go-bython -d ./src -od ./output -I ./include -deps build.d"))
+		fmt.Println(fmt.Sprintf("    go-bython -d ./src -od ./output -preserve-mtime"))
 	}
 }
@@ -0,0 +1,341 @@
+package processor
+
+import "strings"
+
+// literalItem is an element of a literal being laid out, or a comment on a
+// line of its own when it has no tokens
+type literalItem struct {
+	tokens  []token
+	comment string
+}
+
+// literalSource is the text of a collected literal and its tokens
+type literalSource struct {
+	src    string
+	starts []int
+	tokens []token
+}
+
+func newLiteralSource(lines []string) literalSource {
+	s := literalSource{src: strings.Join(lines, "\n")}
+	s.starts = append(s.starts, 0)
+	for i := 0; i < len(s.src); i++ {
+		if s.src[i] == '\n' {
+			s.starts = append(s.starts, i+1)
+		}
+	}
+	s.tokens = tokenize(s.src)
+	return s
+}
+
+func (s literalSource) offset(tok token) int {
+	return s.starts[tok.line-1] + tok.col
+}
+
+// startLiteral begins collecting a multi-line list, dict or set literal when
+// canonical layout is enabled and trimmed leaves one open
+func (p *PythonPreprocessor) startLiteral(line, trimmed string) ([]string, bool) {
+	if p.literals != LiteralsCanonical || p.replaying {
+		return nil, false
+	}
+	code, _ := splitTrailingComment(trimmed)
+	if p.isControlStatement(code) || p.openLiteral(tokenize(code), code) == -1 {
+		return nil, false
+	}
+	p.literal = []string{}
+	p.literalDepth = 0
+	return p.collectLiteral(line), true
+}
+
+// openLiteral returns the index of the outermost list, dict or set left open
+// by the tokens of a line, or -1 if there is none
+func (p *PythonPreprocessor) openLiteral(tokens []token, code string) int {
+	var open []int
+	for i, tok := range tokens {
+		if tok.line > 1 {
+			break
+		}
+		if tok.kind != tokenOp {
+			continue
+		}
+		if isOpenBracket(tok.text) {
+			open = append(open, i)
+		} else if isCloseBracket(tok.text) && len(open) > 0 {
+			open = open[:len(open)-1]
+		}
+	}
+	for _, i := range open {
+		switch tokens[i].text {
+		case "[":
+			return i
+		case "{":
+			if p.isDictionaryBrace(code, tokens[i].col) {
+				return i
+			}
+			return -1
+		}
+	}
+	return -1
+}
+
+// collectLiteral adds a line to the literal being collected, laying it out
+// once its brackets balance. Literals that cannot be laid out canonically,
+// such as comprehensions or ones holding multi-line strings, are processed
+// line by line as usual.
+func (p *PythonPreprocessor) collectLiteral(line string) []string {
+	p.literal = append(p.literal, line)
+	if unterminatedTripleQuote(line) != "" {
+		return p.replayLiteral()
+	}
+	for _, tok := range tokenize(line) {
+		if tok.kind != tokenOp {
+			continue
+		}
+		if isOpenBracket(tok.text) {
+			p.literalDepth++
+		} else if isCloseBracket(tok.text) {
+			p.literalDepth--
+		}
+	}
+	if p.literalDepth > 0 {
+		return []string{}
+	}
+
+	lines, ok := p.layoutLiteral(newLiteralSource(p.literal))
+	if !ok {
+		return p.replayLiteral()
+	}
+	p.literal = nil
+	return lines
+}
+
+// replayLiteral processes the collected lines as if canonical layout was off
+func (p *PythonPreprocessor) replayLiteral() []string {
+	lines := p.literal
+	p.literal = nil
+	p.replaying = true
+	defer func() { p.replaying = false }()
+
+	var result []string
+	for _, line := range lines {
+		result = append(result, p.processLine(line)...)
+	}
+	return result
+}
+
+// layoutLiteral lays out a collected statement whose first line ends with
+// the bracket opening a literal
+func (p *PythonPreprocessor) layoutLiteral(s literalSource) ([]string, bool) {
+	firstLine, _, _ := strings.Cut(s.src, "\n")
+	open := p.openLiteral(s.tokens, firstLine)
+	if open == -1 {
+		return nil, false
+	}
+	close := closingBracket(s.tokens, open)
+	if close == -1 {
+		return nil, false
+	}
+	for _, tok := range s.tokens[close+1:] {
+		if tok.kind == tokenNewline {
+			return nil, false
+		}
+	}
+
+	rest := strings.TrimSpace(s.src[s.offset(s.tokens[close])+1:])
+	restCode, restComment := splitTrailingComment(rest)
+	restCode = p.trimSemicolon(strings.TrimSpace(restCode))
+	if strings.HasSuffix(restCode, "{") {
+		return nil, false
+	}
+	closing := s.tokens[close].text + restCode
+	if restComment != "" {
+		closing += "  " + restComment
+	}
+
+	header := strings.TrimSpace(s.src[:s.offset(s.tokens[open])+1])
+	body, headerComment, ok := p.layoutElements(s, open, close, 1)
+	if !ok {
+		return nil, false
+	}
+	if len(body) == 0 && headerComment == "" {
+		return []string{p.indent() + header + closing}, true
+	}
+	if headerComment != "" {
+		header += "  " + headerComment
+	}
+	lines := append([]string{p.indent() + header}, body...)
+	return append(lines, p.indent()+closing), true
+}
+
+// layoutElements returns the lines of the elements between the brackets at
+// open and close, one element per line at the given nesting level, and the
+// comment that follows the opening bracket
+func (p *PythonPreprocessor) layoutElements(s literalSource, open, close, level int) ([]string, string, bool) {
+	items, headerComment, ok := splitLiteral(s.tokens, open, close)
+	if !ok {
+		return nil, "", false
+	}
+
+	last := -1
+	for i, item := range items {
+		if len(item.tokens) > 0 {
+			last = i
+		}
+	}
+
+	indentation := p.indent() + strings.Repeat(p.indentChar, level)
+	var lines []string
+	for i, item := range items {
+		if len(item.tokens) == 0 {
+			lines = append(lines, indentation+item.comment)
+			continue
+		}
+		element, ok := p.layoutElement(s, item.tokens, level)
+		if !ok {
+			return nil, "", false
+		}
+		element[0] = indentation + element[0]
+		end := len(element) - 1
+		if i != last || p.trailingCommas {
+			element[end] += ","
+		}
+		if item.comment != "" {
+			element[end] += "  " + item.comment
+		}
+		lines = append(lines, element...)
+	}
+	return lines, headerComment, true
+}
+
+// layoutElement returns the lines of one element, the first without its
+// indentation. An element ending in a literal that spans several lines in
+// the source, such as a nested list or a dict value, is laid out in turn.
+func (p *PythonPreprocessor) layoutElement(s literalSource, tokens []token, level int) ([]string, bool) {
+	end := len(tokens) - 1
+	if tokens[end].kind == tokenOp && (tokens[end].text == "]" || tokens[end].text == "}") {
+		for open := 0; open < end; open++ {
+			if !isNestedLiteral(tokens, open) || closingBracket(tokens, open) != end ||
+				tokens[open].line == tokens[end].line {
+				continue
+			}
+			// the nested elements are split from s.tokens, which keeps newlines
+			first, last := tokenIndex(s.tokens, tokens[open]), tokenIndex(s.tokens, tokens[end])
+			body, headerComment, ok := p.layoutElements(s, first, last, level+1)
+			if !ok {
+				return nil, false
+			}
+			prefix, ok := s.collapse(tokens[:open+1])
+			if !ok {
+				return nil, false
+			}
+			if len(body) == 0 && headerComment == "" {
+				return []string{prefix + tokens[end].text}, true
+			}
+			if headerComment != "" {
+				prefix += "  " + headerComment
+			}
+			lines := append([]string{prefix}, body...)
+			return append(lines, p.indent()+strings.Repeat(p.indentChar, level)+tokens[end].text), true
+		}
+	}
+
+	text, ok := s.collapse(tokens)
+	return []string{text}, ok
+}
+
+// isNestedLiteral reports whether tokens[open] opens a list, dict or set
+// that is a whole element or the value of a dict entry
+func isNestedLiteral(tokens []token, open int) bool {
+	tok := tokens[open]
+	if tok.kind != tokenOp || (tok.text != "[" && tok.text != "{") {
+		return false
+	}
+	return open == 0 || (tokens[open-1].kind == tokenOp && tokens[open-1].text == ":")
+}
+
+func tokenIndex(tokens []token, tok token) int {
+	for i, t := range tokens {
+		if t == tok {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitLiteral splits the tokens between the brackets at open and close into
+// elements at top-level commas, attaching each trailing comment to the
+// element before it. It fails for comprehensions, which have no elements.
+func splitLiteral(tokens []token, open, close int) ([]literalItem, string, bool) {
+	var items []literalItem
+	var current literalItem
+	headerComment := ""
+	depth := 0
+	prev := open
+
+	for i := open + 1; i < close; i++ {
+		tok := tokens[i]
+		switch {
+		case tok.kind == tokenNewline:
+			continue
+		case tok.kind == tokenComment && depth == 0:
+			sameLine := tokens[prev].line == tok.line
+			switch {
+			case sameLine && prev == open:
+				headerComment = tok.text
+			case sameLine && len(current.tokens) > 0:
+				current.comment = tok.text
+			case sameLine && tokens[prev].text == "," && len(items) > 0 && items[len(items)-1].comment == "":
+				items[len(items)-1].comment = tok.text
+			default:
+				items = append(items, literalItem{comment: tok.text})
+			}
+			continue
+		case tok.kind == tokenName && tok.text == "for" && depth == 0:
+			return nil, "", false
+		case tok.kind == tokenOp && isOpenBracket(tok.text):
+			depth++
+		case tok.kind == tokenOp && isCloseBracket(tok.text):
+			depth--
+		case tok.kind == tokenOp && tok.text == "," && depth == 0:
+			if len(current.tokens) == 0 {
+				return nil, "", false
+			}
+			items = append(items, current)
+			current = literalItem{}
+			prev = i
+			continue
+		}
+		if current.comment != "" {
+			// a comment inside a multi-line element
+			return nil, "", false
+		}
+		current.tokens = append(current.tokens, tok)
+		prev = i
+	}
+	if len(current.tokens) > 0 {
+		items = append(items, current)
+	}
+	return items, headerComment, true
+}
+
+// collapse returns tokens as a single line, keeping the spacing between
+// tokens on the same source line and joining lines with a space
+func (s literalSource) collapse(tokens []token) (string, bool) {
+	var b strings.Builder
+	for i, tok := range tokens {
+		if tok.kind == tokenComment || strings.Contains(tok.text, "\n") {
+			return "", false
+		}
+		if i > 0 {
+			prev := tokens[i-1]
+			switch {
+			case prev.line == tok.line:
+				b.WriteString(s.src[s.offset(prev)+len(prev.text) : s.offset(tok)])
+			case !isOpenBracket(prev.text) && !isCloseBracket(tok.text):
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(tok.text)
+	}
+	return b.String(), true
+}
@@ -0,0 +1,138 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalLiterals(t *testing.T) {
+	//given
+	input := `config = {"name": "test", "retries": 3,
+    "hosts": [
+        "a", "b"],  # primary first
+}`
+
+	tests := []struct {
+		name     string
+		commas   bool
+		expected string
+	}{
+		{"trailing commas", true, `config = {
+    "name": "test",
+    "retries": 3,
+    "hosts": [
+        "a",
+        "b",
+    ],  # primary first
+}
+`},
+		{"no trailing commas", false, `config = {
+    "name": "test",
+    "retries": 3,
+    "hosts": [
+        "a",
+        "b"
+    ]  # primary first
+}
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPythonPreprocessorWithOptions(WithLiterals(LiteralsCanonical), WithTrailingCommas(tt.commas))
+
+			//when
+			result, err := p.ProcessString(input)
+
+			//then
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+			assert.Empty(t, p.Verify(input, result))
+		})
+	}
+}
+
+func TestCanonicalLiteralsInBlocks(t *testing.T) {
+	//given
+	input := `def load() {
+    values = foo([  # header
+          1, 2,
+          # the last one
+          {"a": 1}
+    ]);
+    empty = {
+    }
+    return values;
+}`
+
+	expected := `def load():
+  values = foo([  # header
+    1,
+    2,
+    # the last one
+    {"a": 1}
+  ])
+  empty = {}
+  return values
+`
+
+	p := NewPythonPreprocessorWithOptions(WithIndentSize(2), WithLiterals(LiteralsCanonical))
+
+	//when
+	result, err := p.ProcessString(input)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Verify(input, result))
+}
+
+func TestCanonicalLiteralsKeepComprehensions(t *testing.T) {
+	//given
+	input := `squares = {
+    n: n * n
+    for n in range(10)
+}
+x = 1`
+
+	expected := `squares = {
+    n: n * n
+    for n in range(10)
+}
+x = 1
+`
+
+	p := NewPythonPreprocessorWithOptions(WithLiterals(LiteralsCanonical))
+
+	//when
+	result, err := p.ProcessString(input)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+}
+
+func TestCanonicalLiteralsPragma(t *testing.T) {
+	//given
+	input := `# bython: literals=canonical, trailingcommas=true
+items = [
+  1, 2]`
+
+	expected := `# bython: literals=canonical, trailingcommas=true
+items = [
+    1,
+    2,
+]
+`
+
+	p := NewPythonPreprocessor(4)
+
+	//when
+	result, err := p.ProcessString(input)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Diagnostics())
+}
@@ -0,0 +1,406 @@
+package processor
+
+import "strings"
+
+// pythonKeywords cannot end an operand, so a // after one starts a comment
+var pythonKeywords = map[string]bool{
+	"and": true, "as": true, "assert": true, "async": true, "await": true, "break": true,
+	"class": true, "continue": true, "def": true, "del": true, "elif": true, "else": true,
+	"except": true, "finally": true, "for": true, "from": true, "global": true, "if": true,
+	"import": true, "in": true, "is": true, "lambda": true, "nonlocal": true, "not": true,
+	"or": true, "pass": true, "raise": true, "return": true, "try": true, "while": true,
+	"with": true, "yield": true,
+}
+
+// rewriteCComments converts // line comments and /* */ block comments into #
+// comments. Block comments may span lines; comments in the middle of code
+// move to the end of the line since Python has no inline comments.
+func (p *PythonPreprocessor) rewriteCComments(code string) string {
+	if !p.inBlockComment && !strings.Contains(code, "//") && !strings.Contains(code, "/*") {
+		p.recordNames(code)
+		return code
+	}
+
+	indentation := code[:leadingWidth(code)]
+	var kept strings.Builder
+	var comments []string
+	commentOnly := true
+
+	i := 0
+	if p.inBlockComment {
+		end := strings.Index(code, "*/")
+		if end == -1 {
+			return indentation + formatComment([]string{blockCommentText(code)})
+		}
+		comments = append(comments, blockCommentText(code[:end]))
+		p.inBlockComment = false
+		i = end + 2
+	}
+
+	for i < len(code) {
+		ch := code[i]
+
+		switch {
+		case ch == '"' || ch == '\'':
+			end, _, _ := scanString(code, i)
+			kept.WriteString(code[i:end])
+			i = end
+			commentOnly = false
+			continue
+		case ch == '#':
+			comments = append(comments, strings.TrimSpace(code[i+1:]))
+			i = len(code)
+			continue
+		case strings.HasPrefix(code[i:], "/*"):
+			end := strings.Index(code[i+2:], "*/")
+			if end == -1 {
+				comments = append(comments, blockCommentText(code[i+2:]))
+				p.inBlockComment = true
+				i = len(code)
+				continue
+			}
+			comments = append(comments, blockCommentText(code[i+2:i+2+end]))
+			i += end + 4
+			// avoid leaving a double space where the comment was
+			if k := kept.Len(); k > 0 && (kept.String()[k-1] == ' ' || kept.String()[k-1] == '\t') {
+				for i < len(code) && (code[i] == ' ' || code[i] == '\t') {
+					i++
+				}
+			}
+			continue
+		case strings.HasPrefix(code[i:], "//") && !strings.HasPrefix(code[i:], "//="):
+			if p.isLineComment(kept.String(), code[i+2:]) {
+				comments = append(comments, strings.TrimSpace(code[i+2:]))
+				i = len(code)
+				continue
+			}
+			kept.WriteString("//")
+			i += 2
+			commentOnly = false
+			continue
+		}
+
+		if ch != ' ' && ch != '\t' {
+			commentOnly = false
+		}
+		kept.WriteByte(ch)
+		i++
+	}
+
+	if commentOnly {
+		return indentation + formatComment(comments)
+	}
+
+	result := strings.TrimRight(kept.String(), " \t")
+	p.recordNames(result)
+	if len(comments) > 0 {
+		result += "  " + formatComment(comments)
+	}
+	return result
+}
+
+// formatComment joins comment texts into a single # comment
+func formatComment(texts []string) string {
+	var parts []string
+	for _, text := range texts {
+		if text != "" {
+			parts = append(parts, text)
+		}
+	}
+	if len(parts) == 0 {
+		return "#"
+	}
+	return "# " + strings.Join(parts, " ")
+}
+
+// blockCommentText strips the whitespace and leading * decoration from a line
+// of a block comment
+func blockCommentText(text string) string {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "*") {
+		text = strings.TrimSpace(text[1:])
+	}
+	return text
+}
+
+// isLineComment decides whether a // between before and after starts a
+// comment or is floor division. It is division when an operand ends before it
+// and the text after it continues the expression; anything else is a comment.
+// A division that could also have been meant as a comment, a lone unknown
+// word after it or a // set off by two or more spaces, is reported.
+func (p *PythonPreprocessor) isLineComment(before, after string) bool {
+	prev := significantTokens(before)
+	if len(prev) == 0 {
+		return true
+	}
+	last := prev[len(prev)-1]
+	switch last.kind {
+	case tokenOp:
+		if !isCloseBracket(last.text) {
+			return true
+		}
+	case tokenName:
+		if pythonKeywords[last.text] {
+			return true
+		}
+	}
+
+	next := significantTokens(after)
+	if !continuesExpression(prev, next) {
+		return true
+	}
+
+	spaced := len(before)-len(strings.TrimRight(before, " \t")) >= 2
+	// a lone word ending the statement, as in "f() // call"
+	word := next
+	for len(word) > 1 && word[len(word)-1].kind == tokenOp && (word[len(word)-1].text == ";" || word[len(word)-1].text == "}") {
+		word = word[:len(word)-1]
+	}
+	unknown := len(word) == 1 && word[0].kind == tokenName && !p.names[word[0].text] && !hasName(prev, word[0].text)
+	if spaced || unknown {
+		p.warn("'// %s' is ambiguous and read as floor division; write comments with # or /* */",
+			after[word[0].col:word[len(word)-1].col+len(word[len(word)-1].text)])
+	}
+	return false
+}
+
+// continuesExpression reports whether next, the tokens after a // that
+// follows the tokens prev, parse as the rest of the expression: a divisor
+// followed by more operators, the closing brackets and separators prev left
+// open, or the end of the statement
+func continuesExpression(prev, next []token) bool {
+	var open []string
+	for _, tok := range prev {
+		switch {
+		case tok.kind != tokenOp:
+		case isOpenBracket(tok.text):
+			open = append(open, tok.text)
+		case isCloseBracket(tok.text) && len(open) > 0:
+			open = open[:len(open)-1]
+		}
+	}
+	header := prev[0].kind == tokenName && compoundKeywords[prev[0].text]
+
+	s := &exprScanner{tokens: next}
+	if !s.expression() {
+		return false
+	}
+	for !s.done() {
+		tok := s.tokens[s.pos]
+		if tok.kind == tokenName {
+			switch tok.text {
+			case "if", "else":
+				s.pos++
+				if !s.expression() {
+					return false
+				}
+				continue
+			case "for", "async":
+				// the rest of a comprehension
+				return len(open) > 0
+			case "as":
+				s.pos++
+				if !s.expression() {
+					return false
+				}
+				continue
+			}
+			return false
+		}
+		if tok.kind != tokenOp {
+			return false
+		}
+
+		switch text := tok.text; {
+		case text == "//" || text == ";" || text == "{":
+			// a further // is judged on its own and the others end the statement
+			return true
+		case isCloseBracket(text):
+			if len(open) == 0 {
+				return text == "}"
+			}
+			if closes(open[len(open)-1]) != text {
+				return false
+			}
+			open = open[:len(open)-1]
+			s.pos++
+			if !s.tail() {
+				return false
+			}
+		case text == "," || text == ":" && len(open) > 0 || text == "=" && len(open) > 0 && open[len(open)-1] == "(":
+			s.pos++
+			if !s.done() && !isCloseBracket(s.tokens[s.pos].text) && !s.expression() {
+				return false
+			}
+		case text == ":" && header:
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// closes returns the bracket closing open
+func closes(open string) string {
+	switch open {
+	case "(":
+		return ")"
+	case "[":
+		return "]"
+	}
+	return "}"
+}
+
+// exprScanner recognises the operand expressions of a line of tokens, with
+// an unclosed bracket accepted as continuing on the next line
+type exprScanner struct {
+	tokens []token
+	pos    int
+}
+
+func (s *exprScanner) done() bool {
+	return s.pos >= len(s.tokens)
+}
+
+// expression reads operands joined by binary operators, stopping before a
+// // since that is judged on its own
+func (s *exprScanner) expression() bool {
+	if !s.operand() {
+		return false
+	}
+	return s.tail()
+}
+
+// tail reads the trailers and binary operators that may follow an operand
+func (s *exprScanner) tail() bool {
+	if !s.trailers() {
+		return false
+	}
+	for !s.done() && isBinaryOperator(s.tokens[s.pos]) {
+		s.pos++
+		if s.pos < len(s.tokens) && s.tokens[s.pos-1].text == "not" && s.tokens[s.pos].text == "in" {
+			s.pos++
+		}
+		if !s.operand() || !s.trailers() {
+			return false
+		}
+	}
+	return true
+}
+
+// operand reads prefix operators and an atom
+func (s *exprScanner) operand() bool {
+	for !s.done() {
+		tok := s.tokens[s.pos]
+		if tok.kind == tokenOp && (tok.text == "-" || tok.text == "+" || tok.text == "~") ||
+			tok.kind == tokenName && (tok.text == "await" || tok.text == "not") {
+			s.pos++
+			continue
+		}
+		break
+	}
+	if s.done() {
+		return false
+	}
+
+	switch tok := s.tokens[s.pos]; {
+	case tok.kind == tokenName:
+		if pythonKeywords[tok.text] {
+			return false
+		}
+		s.pos++
+	case tok.kind == tokenNumber:
+		s.pos++
+	case tok.kind == tokenString:
+		for !s.done() && s.tokens[s.pos].kind == tokenString {
+			s.pos++
+		}
+	case tok.kind == tokenOp && tok.text == "...":
+		s.pos++
+	case tok.kind == tokenOp && isOpenBracket(tok.text):
+		return s.group()
+	default:
+		return false
+	}
+	return true
+}
+
+// trailers reads attribute, call and subscript trailers
+func (s *exprScanner) trailers() bool {
+	for !s.done() {
+		switch tok := s.tokens[s.pos]; {
+		case tok.kind == tokenOp && tok.text == ".":
+			if s.pos+1 >= len(s.tokens) || s.tokens[s.pos+1].kind != tokenName {
+				return false
+			}
+			s.pos += 2
+		case tok.kind == tokenOp && (tok.text == "(" || tok.text == "["):
+			if !s.group() {
+				return false
+			}
+		default:
+			return true
+		}
+	}
+	return true
+}
+
+// group skips the bracketed tokens starting at the current one; a group left
+// open continues on the next line and ends the scan
+func (s *exprScanner) group() bool {
+	close := closingBracket(s.tokens, s.pos)
+	if close == -1 {
+		s.pos = len(s.tokens)
+		return true
+	}
+	s.pos = close + 1
+	return true
+}
+
+func isBinaryOperator(tok token) bool {
+	if tok.kind == tokenName {
+		switch tok.text {
+		case "and", "or", "in", "is", "not":
+			return true
+		}
+		return false
+	}
+	switch tok.text {
+	case "+", "-", "*", "/", "%", "**", "@", "<<", ">>", "&", "|", "^", "<", ">", "<=", ">=", "==", "!=":
+		return true
+	}
+	return false
+}
+
+// recordNames remembers the names used in code, so that a // before one of
+// them is not reported as ambiguous
+func (p *PythonPreprocessor) recordNames(code string) {
+	for _, tok := range tokenize(code) {
+		if tok.kind == tokenName && !pythonKeywords[tok.text] {
+			if p.names == nil {
+				p.names = make(map[string]bool)
+			}
+			p.names[tok.text] = true
+		}
+	}
+}
+
+func hasName(tokens []token, name string) bool {
+	for _, tok := range tokens {
+		if tok.kind == tokenName && tok.text == name {
+			return true
+		}
+	}
+	return false
+}
+
+func significantTokens(code string) []token {
+	var tokens []token
+	for _, tok := range tokenize(code) {
+		if tok.kind != tokenComment && tok.kind != tokenNewline {
+			tokens = append(tokens, tok)
+		}
+	}
+	return tokens
+}
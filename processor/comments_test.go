@@ -0,0 +1,124 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCStyleLineComments(t *testing.T) {
+	//given
+	input := `// Entry point
+def halve(total, count) {
+    half = total // count;
+    rest = total // 2 // fall back to halves
+    url = "http://example.com" // keep the URL
+    return half + rest;
+}`
+
+	expected := `# Entry point
+def halve(total, count):
+  half = total // count
+  rest = total // 2  # fall back to halves
+  url = "http://example.com"  # keep the URL
+  return half + rest
+`
+
+	p := NewPythonPreprocessor(2)
+	p.EnableExtensions(ExtCComments)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+}
+
+func TestCStyleBlockComments(t *testing.T) {
+	//given
+	input := `/*
+ * Helpers for the report.
+ */
+def report(x) {
+    y = x /* scaled */ * 2
+    /* multi-line
+       comment */ print(y)
+}`
+
+	expected := `#
+# Helpers for the report.
+#
+def report(x):
+  y = x * 2  # scaled
+  # multi-line
+  print(y)  # comment
+`
+
+	p := NewPythonPreprocessor(2)
+	p.EnableExtensions(ExtCComments)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Verify(input, result))
+}
+
+func TestCStyleCommentsAfterOperands(t *testing.T) {
+	//given
+	input := `from config import *
+def run(n, step, d) {
+    f(1) // TODO: fix
+    g(n) // note, see below
+    w = n // step
+    v = f(n // step, d[n // 2:], k=n // 3)
+    if n // step > 1 { return n // PAGE }
+    z = n  // TODO
+}`
+
+	expected := `from config import *
+def run(n, step, d):
+  f(1)  # TODO: fix
+  g(n)  # note, see below
+  w = n // step
+  v = f(n // step, d[n // 2:], k=n // 3)
+  if n // step > 1:
+    return n // PAGE
+  z = n  // TODO
+`
+
+	p := NewPythonPreprocessor(2)
+	p.EnableExtensions(ExtCComments)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Equal(t, []Diagnostic{
+		{Line: 7, Message: "'// PAGE' is ambiguous and read as floor division; write comments with # or /* */"},
+		{Line: 8, Message: "'// TODO' is ambiguous and read as floor division; write comments with # or /* */"},
+	}, p.Diagnostics())
+	assert.Empty(t, p.Verify(input, result))
+}
+
+func TestCStyleAmbiguousCommentStrict(t *testing.T) {
+	//given
+	input := `def run() {
+    f() // call
+}`
+
+	p := NewPythonPreprocessorWithOptions(WithIndentSize(2), WithExtensions(ExtCComments), WithStrict(true))
+
+	//when
+	_, err := p.ProcessString(input)
+
+	//then
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "'// call' is ambiguous")
+	}
+}
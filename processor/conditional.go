@@ -0,0 +1,189 @@
+package processor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// conditional is an open #!if block
+type conditional struct {
+	parent  bool // the lines around the block are compiled
+	active  bool // the current branch is compiled
+	taken   bool // an earlier branch was compiled
+	sawElse bool
+}
+
+// Define sets a flag for #!if conditions; a flag defined without a value
+// should be given "1"
+func (p *PythonPreprocessor) Define(name, value string) {
+	if p.flags == nil {
+		p.flags = make(map[string]string)
+	}
+	p.flags[name] = value
+}
+
+// compiled reports whether lines at the current position are compiled
+func (p *PythonPreprocessor) compiled() bool {
+	n := len(p.conditionals)
+	return n == 0 || (p.conditionals[n-1].parent && p.conditionals[n-1].active)
+}
+
+// evalCondition evaluates the condition of #!if or #!elif, reporting
+// conditions that cannot be parsed and treating them as false
+func (p *PythonPreprocessor) evalCondition(condition string) bool {
+	e := conditionParser{lookup: p.flagValue, tokens: significantTokens(condition)}
+	value, err := e.or()
+	if err == nil && e.pos < len(e.tokens) {
+		err = fmt.Errorf("unexpected '%s'", e.tokens[e.pos].text)
+	}
+	if err != nil {
+		p.warn("invalid condition '%s': %v", condition, err)
+		return false
+	}
+	return value
+}
+
+// flagValue returns the value of a name in a condition: an object-like macro
+// defined in the source, or else a flag
+func (p *PythonPreprocessor) flagValue(name string) (string, bool) {
+	if m, ok := p.macros[name]; ok && !m.function {
+		return m.body, true
+	}
+	value, ok := p.flags[name]
+	return value, ok
+}
+
+// conditionParser evaluates conditions built from flag names, comparisons
+// with == and !=, defined(NAME), not/!, and/&&, or/|| and parentheses
+type conditionParser struct {
+	lookup func(name string) (string, bool)
+	tokens []token
+	pos    int
+}
+
+func (e *conditionParser) peek(text string) bool {
+	return e.pos < len(e.tokens) && e.tokens[e.pos].text == text
+}
+
+// accept consumes the operator or keyword spelt by any of words; && and ||
+// arrive from the tokenizer as two single character tokens
+func (e *conditionParser) accept(words ...string) bool {
+	for _, word := range words {
+		if word == "&&" || word == "||" {
+			if isOpPair(e.tokens, e.pos, word[:1]) {
+				e.pos += 2
+				return true
+			}
+			continue
+		}
+		if e.peek(word) {
+			e.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (e *conditionParser) or() (bool, error) {
+	value, err := e.and()
+	for err == nil && e.accept("or", "||") {
+		var right bool
+		right, err = e.and()
+		value = value || right
+	}
+	return value, err
+}
+
+func (e *conditionParser) and() (bool, error) {
+	value, err := e.unary()
+	for err == nil && e.accept("and", "&&") {
+		var right bool
+		right, err = e.unary()
+		value = value && right
+	}
+	return value, err
+}
+
+func (e *conditionParser) unary() (bool, error) {
+	if e.accept("not", "!") {
+		value, err := e.unary()
+		return !value, err
+	}
+	return e.primary()
+}
+
+func (e *conditionParser) primary() (bool, error) {
+	if e.accept("(") {
+		value, err := e.or()
+		if err == nil && !e.accept(")") {
+			err = fmt.Errorf("missing ')'")
+		}
+		return value, err
+	}
+
+	if e.accept("defined") {
+		parens := e.accept("(")
+		name, err := e.name()
+		if err == nil && parens && !e.accept(")") {
+			err = fmt.Errorf("missing ')'")
+		}
+		_, defined := e.lookup(name)
+		return defined, err
+	}
+
+	left, defined, err := e.operand()
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case e.accept("=="):
+		right, _, err := e.operand()
+		return left == right, err
+	case e.accept("!="):
+		right, _, err := e.operand()
+		return left != right, err
+	}
+	return defined && isTruthy(left), nil
+}
+
+// operand returns the value of a flag name or literal, and whether it is
+// defined
+func (e *conditionParser) operand() (string, bool, error) {
+	if e.pos >= len(e.tokens) {
+		return "", false, fmt.Errorf("missing operand")
+	}
+	tok := e.tokens[e.pos]
+	e.pos++
+	switch tok.kind {
+	case tokenName:
+		value, ok := e.lookup(tok.text)
+		return value, ok, nil
+	case tokenNumber:
+		return tok.text, true, nil
+	case tokenString:
+		value, err := strconv.Unquote(tok.text)
+		if err != nil {
+			value = strings.Trim(tok.text, `"'`)
+		}
+		return value, true, nil
+	}
+	return "", false, fmt.Errorf("unexpected '%s'", tok.text)
+}
+
+func (e *conditionParser) name() (string, error) {
+	if e.pos >= len(e.tokens) || e.tokens[e.pos].kind != tokenName {
+		return "", fmt.Errorf("expected a flag name")
+	}
+	e.pos++
+	return e.tokens[e.pos-1].text, nil
+}
+
+// isTruthy reports whether a flag value counts as set
+func isTruthy(value string) bool {
+	switch strings.ToLower(value) {
+	case "", "0", "false", "no", "off":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConditionalCompilation(t *testing.T) {
+	//given
+	input := `#!/usr/bin/env python3
+def run() {
+#!if DEBUG
+    print("debug")
+#!elif TARGET == "prod" && !defined(QUIET)
+    log("prod")
+    #!if VERBOSE
+    log("verbose")
+    #!endif
+#!else
+    pass
+#!endif
+    work()
+}`
+
+	tests := []struct {
+		name     string
+		flags    map[string]string
+		expected string
+	}{
+		{"debug", map[string]string{"DEBUG": "1", "TARGET": "prod"}, "#!/usr/bin/env python3\ndef run():\n  print(\"debug\")\n  work()\n"},
+		{"prod", map[string]string{"DEBUG": "0", "TARGET": "prod", "VERBOSE": "yes"}, "#!/usr/bin/env python3\ndef run():\n  log(\"prod\")\n  log(\"verbose\")\n  work()\n"},
+		{"quiet", map[string]string{"TARGET": "prod", "QUIET": ""}, "#!/usr/bin/env python3\ndef run():\n  pass\n  work()\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPythonPreprocessor(2)
+			for name, value := range tt.flags {
+				p.Define(name, value)
+			}
+
+			//when
+			result, err := p.ProcessString(input)
+			assert.NoError(t, err)
+
+			//then
+			assert.Equal(t, tt.expected, result)
+			assert.Empty(t, p.Diagnostics())
+			assert.Empty(t, p.Verify(input, result))
+		})
+	}
+}
+
+func TestConditionalDiagnostics(t *testing.T) {
+	//given
+	input := `#!if (A or
+x = 1
+#!endif
+#!else
+#!if B`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, "", result)
+	diagnostics := p.Diagnostics()
+	assert.Len(t, diagnostics, 3)
+	assert.Contains(t, diagnostics[0].Message, "invalid condition")
+	assert.Equal(t, 4, diagnostics[1].Line)
+	assert.Contains(t, diagnostics[1].Message, "'#!else' without '#!if'")
+	assert.Contains(t, diagnostics[2].Message, "not closed at end of input")
+}
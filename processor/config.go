@@ -0,0 +1,228 @@
+package processor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SemicolonMode controls what happens to statement-terminating semicolons
+type SemicolonMode int
+
+const (
+	// SemicolonsStrip removes trailing semicolons
+	SemicolonsStrip SemicolonMode = iota
+	// SemicolonsKeep leaves semicolons untouched
+	SemicolonsKeep
+	// SemicolonsSplit puts each semicolon-separated statement on its own line
+	SemicolonsSplit
+)
+
+var semicolonModes = map[string]SemicolonMode{
+	"strip": SemicolonsStrip,
+	"keep":  SemicolonsKeep,
+	"split": SemicolonsSplit,
+}
+
+// ParseSemicolonMode parses "strip", "keep" or "split"
+func ParseSemicolonMode(s string) (SemicolonMode, error) {
+	mode, ok := semicolonModes[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown semicolon mode '%s' (want strip, keep or split)", s)
+	}
+	return mode, nil
+}
+
+func (m SemicolonMode) String() string {
+	for name, mode := range semicolonModes {
+		if mode == m {
+			return name
+		}
+	}
+	return strconv.Itoa(int(m))
+}
+
+// LiteralStyle controls the layout of multi-line list, dict and set literals
+type LiteralStyle int
+
+const (
+	// LiteralsKeep keeps the source's relative layout
+	LiteralsKeep LiteralStyle = iota
+	// LiteralsCanonical puts one element per line, nested one level deeper
+	// than the line that opens the literal
+	LiteralsCanonical
+)
+
+var literalStyles = map[string]LiteralStyle{
+	"keep":      LiteralsKeep,
+	"canonical": LiteralsCanonical,
+}
+
+// ParseLiteralStyle parses "keep" or "canonical"
+func ParseLiteralStyle(s string) (LiteralStyle, error) {
+	style, ok := literalStyles[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown literal style '%s' (want keep or canonical)", s)
+	}
+	return style, nil
+}
+
+func (s LiteralStyle) String() string {
+	for name, style := range literalStyles {
+		if style == s {
+			return name
+		}
+	}
+	return strconv.Itoa(int(s))
+}
+
+// fileConfig holds the settings a "# bython: key=value, ..." header comment
+// may override for a single file
+type fileConfig struct {
+	indentSize int
+	indentChar string
+	tabWidth   int
+	semicolons SemicolonMode
+	strict     bool
+	literals   LiteralStyle
+	// trailingCommas ends canonical literals with a comma after the last element
+	trailingCommas bool
+}
+
+// applyConfigDirective applies the settings of a header pragma such as
+// "indent=4, tabs=false, tabwidth=8, semicolons=split, strict=true,
+// literals=canonical, trailingcommas=true"
+func (p *PythonPreprocessor) applyConfigDirective(directive string) {
+	tabs := p.indentChar == "\t"
+
+	for _, setting := range strings.Split(directive, ",") {
+		key, value, ok := strings.Cut(setting, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" {
+			p.warn("malformed setting '%s' in bython directive", strings.TrimSpace(setting))
+			continue
+		}
+
+		switch key {
+		case "indent":
+			size, err := strconv.Atoi(value)
+			if err != nil || size <= 0 {
+				p.warn("invalid indent '%s' in bython directive", value)
+				continue
+			}
+			p.indentSize = size
+		case "tabs":
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				p.warn("invalid tabs '%s' in bython directive", value)
+				continue
+			}
+			tabs = enabled
+		case "tabwidth":
+			width, err := strconv.Atoi(value)
+			if err != nil || width <= 0 {
+				p.warn("invalid tabwidth '%s' in bython directive", value)
+				continue
+			}
+			p.tabWidth = width
+		case "semicolons":
+			mode, err := ParseSemicolonMode(value)
+			if err != nil {
+				p.warn("%v in bython directive", err)
+				continue
+			}
+			p.semicolons = mode
+		case "strict":
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				p.warn("invalid strict '%s' in bython directive", value)
+				continue
+			}
+			p.strict = enabled
+		case "literals":
+			style, err := ParseLiteralStyle(value)
+			if err != nil {
+				p.warn("%v in bython directive", err)
+				continue
+			}
+			p.literals = style
+		case "trailingcommas":
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				p.warn("invalid trailingcommas '%s' in bython directive", value)
+				continue
+			}
+			p.trailingCommas = enabled
+		default:
+			p.warn("unknown setting '%s' in bython directive", key)
+		}
+	}
+
+	if tabs {
+		p.indentChar = "\t"
+	} else {
+		p.indentChar = strings.Repeat(" ", p.indentSize)
+	}
+}
+
+// isConfigDirective reports whether a pragma carries settings rather than a
+// region or classification keyword
+func isConfigDirective(directive string) bool {
+	return strings.Contains(directive, "=")
+}
+
+func (p *PythonPreprocessor) trimSemicolon(s string) string {
+	if p.semicolons == SemicolonsKeep {
+		return s
+	}
+	return strings.TrimSuffix(s, ";")
+}
+
+// splitStatements splits a line on the semicolons that separate statements
+// when semicolons=split is in effect; every part keeps the line's indentation
+func (p *PythonPreprocessor) splitStatements(line string) []string {
+	if p.semicolons != SemicolonsSplit || p.verbatim || p.inString || p.dictDepth > 0 || !strings.Contains(line, ";") {
+		return []string{line}
+	}
+
+	indentation := line[:leadingWidth(line)]
+	var parts []string
+	start, brackets := 0, 0
+
+	for _, tok := range tokenize(line) {
+		if tok.kind == tokenComment {
+			break
+		}
+		if tok.kind != tokenOp {
+			continue
+		}
+		// statements inside a brace block on the line are split with it
+		switch tok.text {
+		case "(", "[", "{":
+			brackets++
+		case ")", "]", "}":
+			if brackets > 0 {
+				brackets--
+			}
+		case ";":
+			if brackets == 0 {
+				if part := strings.TrimSpace(line[start:tok.col]); part != "" {
+					parts = append(parts, indentation+part)
+				}
+				start = tok.col + 1
+			}
+		}
+	}
+
+	if rest := strings.TrimSpace(line[start:]); rest != "" {
+		if strings.HasPrefix(rest, "#") && len(parts) > 0 {
+			parts[len(parts)-1] += "  " + rest
+		} else {
+			parts = append(parts, indentation+rest)
+		}
+	}
+	if len(parts) == 0 {
+		return []string{line}
+	}
+	return parts
+}
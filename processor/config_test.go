@@ -0,0 +1,192 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigDirectiveOverridesIndent(t *testing.T) {
+	//given
+	input := `#!/usr/bin/env python3
+# bython: indent=4
+if x {
+    print("x");
+}`
+
+	expected := `#!/usr/bin/env python3
+# bython: indent=4
+if x:
+    print("x")
+`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Diagnostics())
+}
+
+func TestConfigDirectiveAppliesPerFile(t *testing.T) {
+	//given
+	withDirective := `# bython: tabs=true
+if x {
+    y();
+}`
+
+	withoutDirective := `if x {
+    y();
+}`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	first, err := p.ProcessString(withDirective)
+	assert.NoError(t, err)
+	second, err := p.ProcessString(withoutDirective)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, "# bython: tabs=true\nif x:\n\ty()\n", first)
+	assert.Equal(t, "if x:\n  y()\n", second)
+}
+
+func TestConfigDirectiveOnlyInHeader(t *testing.T) {
+	//given
+	input := `x = 1;
+# bython: indent=8
+if x {
+    y();
+}`
+
+	expected := `x = 1
+# bython: indent=8
+if x:
+  y()
+`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+}
+
+func TestSemicolonsSplit(t *testing.T) {
+	//given
+	input := `# bython: semicolons=split
+def f(a) {
+    x = 1; y = "a;b"; print(x, y);  # done
+    if a { a(); b(); }
+}`
+
+	expected := `# bython: semicolons=split
+def f(a):
+  x = 1
+  y = "a;b"
+  print(x, y)  # done
+  if a:
+    a()
+    b()
+`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+}
+
+func TestSemicolonsSplitSingleLineBlocks(t *testing.T) {
+	//given
+	input := `# bython: semicolons=split
+for i in y { c(); d() }
+if a { b(); e(); }
+z = {1: 2}; w = 3`
+
+	expected := `# bython: semicolons=split
+for i in y:
+  c()
+  d()
+if a:
+  b()
+  e()
+z = {1: 2}
+w = 3
+`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Diagnostics())
+}
+
+func TestSemicolonsKeep(t *testing.T) {
+	//given
+	input := `# bython: semicolons=keep
+if x {
+    y();
+}`
+
+	expected := `# bython: semicolons=keep
+if x:
+  y();
+`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+}
+
+func TestStrictModeFailsOnDiagnostics(t *testing.T) {
+	//given
+	input := `# bython: strict=true, colour=red
+if x {
+    y();`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	_, err := p.ProcessString(input)
+
+	//then
+	assert.EqualError(t, err, `strict mode: 2 problem(s):
+line 1: unknown setting 'colour' in bython directive
+line 3: 1 block(s) not closed at end of input`)
+}
+
+func TestConfigDirectiveTabWidth(t *testing.T) {
+	//given
+	input := "# bython: tabs=true, tabwidth=2, indent=2\nvalues = {\n\t\"a\": {\n\t\t\"b\": 1,\n\t},\n}"
+	expected := "# bython: tabs=true, tabwidth=2, indent=2\nvalues = {\n\t\"a\": {\n\t\t\"b\": 1,\n\t},\n}\n"
+
+	p := NewPythonPreprocessor(4)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Diagnostics())
+}
@@ -0,0 +1,113 @@
+package processor
+
+import "strings"
+
+// Style is the block syntax a source file is written in
+type Style int
+
+const (
+	// StyleStandard files have no brace blocks and need no conversion
+	StyleStandard Style = iota
+	// StyleBrace files open blocks with braces only, or have no blocks but
+	// end statements with semicolons
+	StyleBrace
+	// StyleMixed files contain both brace blocks and colon-indented blocks
+	StyleMixed
+)
+
+func (s Style) String() string {
+	switch s {
+	case StyleBrace:
+		return "brace"
+	case StyleMixed:
+		return "mixed"
+	}
+	return "standard"
+}
+
+// StyleReport is the result of DetectStyle; the line fields hold the first
+// brace-opened and colon-opened block headers and the first statement ended
+// by a semicolon, or zero when there is none
+type StyleReport struct {
+	Style         Style
+	BraceLine     int
+	ColonLine     int
+	SemicolonLine int
+}
+
+// DetectStyle classifies source by looking for compound statement headers
+// that open their block with a brace versus ones that end with a colon. A
+// source without either is a brace file when a statement ends with a
+// semicolon, which standard Python does not write.
+func DetectStyle(source string) StyleReport {
+	var report StyleReport
+	var stmt []token
+	brackets := 0
+
+	tokens := tokenize(strings.TrimPrefix(source, byteOrderMark))
+	for i, tok := range tokens {
+		switch tok.kind {
+		case tokenComment:
+			continue
+		case tokenNewline:
+			if brackets == 0 {
+				stmt = stmt[:0]
+			}
+			continue
+		}
+
+		if tok.kind == tokenOp {
+			switch tok.text {
+			case "{":
+				if brackets == 0 && opensBlock(stmt) {
+					if report.BraceLine == 0 {
+						report.BraceLine = stmt[0].line
+					}
+					stmt = stmt[:0]
+					continue
+				}
+				brackets++
+			case "(", "[":
+				brackets++
+			case ")", "]":
+				if brackets > 0 {
+					brackets--
+				}
+			case "}":
+				if brackets > 0 {
+					brackets--
+				} else {
+					stmt = stmt[:0]
+					continue
+				}
+			case ";":
+				if brackets == 0 {
+					if report.SemicolonLine == 0 && len(stmt) > 0 && endsLine(tokens, i+1) {
+						report.SemicolonLine = tok.line
+					}
+					stmt = stmt[:0]
+					continue
+				}
+			case ":":
+				if brackets == 0 && len(stmt) > 0 && stmt[0].kind == tokenName &&
+					compoundKeywords[stmt[0].text] && endsLine(tokens, i+1) {
+					if report.ColonLine == 0 {
+						report.ColonLine = stmt[0].line
+					}
+				}
+			}
+		}
+
+		stmt = append(stmt, tok)
+	}
+
+	switch {
+	case report.BraceLine > 0 && report.ColonLine > 0:
+		report.Style = StyleMixed
+	case report.BraceLine > 0, report.ColonLine == 0 && report.SemicolonLine > 0:
+		report.Style = StyleBrace
+	default:
+		report.Style = StyleStandard
+	}
+	return report
+}
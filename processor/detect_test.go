@@ -0,0 +1,79 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectStyleBrace(t *testing.T) {
+	//given
+	input := `config = {"a": 1};
+if x in {1, 2} {
+    print(f"{x}");
+}`
+
+	//when
+	report := DetectStyle(input)
+
+	//then
+	assert.Equal(t, StyleBrace, report.Style)
+	assert.Equal(t, 2, report.BraceLine)
+	assert.Equal(t, 0, report.ColonLine)
+}
+
+func TestDetectStyleStandard(t *testing.T) {
+	//given
+	input := `data = {
+    "key": lambda x: x,
+}
+values = items[1:]
+
+def foo(a: int) -> dict:
+    return {"a": a}
+`
+
+	//when
+	report := DetectStyle(input)
+
+	//then
+	assert.Equal(t, StyleStandard, report.Style)
+	assert.Equal(t, 0, report.BraceLine)
+	assert.Equal(t, 6, report.ColonLine)
+}
+
+func TestDetectStyleSemicolonsWithoutBlocks(t *testing.T) {
+	//given
+	input := `import os; import sys
+x = f(a;
+      b)
+print(x);
+`
+
+	//when
+	report := DetectStyle(input)
+
+	//then
+	assert.Equal(t, StyleBrace, report.Style)
+	assert.Equal(t, 0, report.BraceLine)
+	assert.Equal(t, 4, report.SemicolonLine)
+}
+
+func TestDetectStyleMixed(t *testing.T) {
+	//given
+	input := `def one() {
+    return 1;
+}
+
+def two():  # standard
+    return 2
+`
+
+	//when
+	report := DetectStyle(input)
+
+	//then
+	assert.Equal(t, StyleMixed, report.Style)
+	assert.Equal(t, 1, report.BraceLine)
+	assert.Equal(t, 5, report.ColonLine)
+}
@@ -0,0 +1,80 @@
+package processor
+
+import "strings"
+
+// directivePrefix starts the preprocessor directives; a shebang line is never
+// mistaken for one since only known directive names are recognised
+const directivePrefix = "#!"
+
+// parseDirective splits a "#!name args" line into its directive name and
+// arguments. Shebang lines and ordinary comments are not directives.
+func parseDirective(trimmed string) (name, args string, ok bool) {
+	if !strings.HasPrefix(trimmed, directivePrefix) {
+		return "", "", false
+	}
+	body := trimmed[len(directivePrefix):]
+	end := 0
+	for end < len(body) && isNameChar(body[end]) {
+		end++
+	}
+	switch body[:end] {
+	case "if", "elif", "else", "endif", "include", "define", "undef":
+		return body[:end], strings.TrimSpace(body[end:]), true
+	}
+	return "", "", false
+}
+
+// skipDirective handles conditional compilation, includes and macro
+// definitions. It returns true when the line is a directive or lies in a
+// branch that is not compiled, in which case it must be dropped from the
+// output.
+func (p *PythonPreprocessor) skipDirective(line string) bool {
+	if p.openQuote != "" {
+		return !p.compiled()
+	}
+	name, args, ok := parseDirective(strings.TrimSpace(line))
+	if !ok {
+		return !p.compiled()
+	}
+
+	n := len(p.conditionals)
+	switch name {
+	case "include":
+		if p.compiled() {
+			p.include(args)
+		}
+	case "define":
+		if p.compiled() {
+			p.defineMacro(args)
+		}
+	case "undef":
+		if p.compiled() {
+			delete(p.macros, args)
+		}
+	case "if":
+		compiled := p.compiled()
+		active := compiled && p.evalCondition(args)
+		p.conditionals = append(p.conditionals, conditional{parent: compiled, active: active, taken: active})
+	case "elif", "else":
+		if n == 0 {
+			p.warn("'#!%s' without '#!if'", name)
+			return true
+		}
+		c := &p.conditionals[n-1]
+		if c.sawElse {
+			p.warn("'#!%s' after '#!else'", name)
+		}
+		c.active = false
+		if !c.taken && c.parent && (name == "else" || p.evalCondition(args)) {
+			c.active, c.taken = true, true
+		}
+		c.sawElse = c.sawElse || name == "else"
+	case "endif":
+		if n == 0 {
+			p.warn("'#!endif' without '#!if'")
+			return true
+		}
+		p.conditionals = p.conditionals[:n-1]
+	}
+	return true
+}
@@ -0,0 +1,185 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// loopFrame records a brace block that a continue statement can target
+type loopFrame struct {
+	depth int
+	do    bool
+	id    int
+}
+
+// isLoopHeader reports whether a block header starts a loop
+func isLoopHeader(header string) bool {
+	return strings.HasPrefix(header, "for ") || strings.HasPrefix(header, "while ") ||
+		strings.HasPrefix(header, "async for ")
+}
+
+// openDoLoop handles "do {", which becomes "while True:". The loop condition
+// is only known at the closing "} while cond;", so output is held back until
+// the loop closes. It returns false when the line is not a do header.
+func (p *PythonPreprocessor) openDoLoop(trimmed string) ([]string, bool) {
+	code, comment := splitTrailingComment(trimmed)
+	if !strings.HasPrefix(code, "do") {
+		return nil, false
+	}
+	rest := strings.TrimSpace(code[len("do"):])
+	if !strings.HasPrefix(rest, "{") {
+		return nil, false
+	}
+
+	result := []string{p.indent() + "while True:"}
+	p.indentLevel++
+	p.structuralBlocks++
+	p.doCount++
+	p.loops = append(p.loops, loopFrame{depth: p.structuralBlocks, do: true, id: p.doCount})
+
+	afterBrace := strings.TrimSpace(rest[1:])
+	// the whole loop may be on one line, as in "do { a() } while b;", and
+	// then its comment goes with the condition
+	if close := bodyEnd(afterBrace); close != -1 {
+		body := p.blockBody(afterBrace[:close])
+		closing := afterBrace[close:]
+		if comment != "" {
+			closing += "  " + comment
+		}
+		return append(result, p.closeDoLoop(closing, body)...), true
+	}
+	if comment != "" {
+		result[0] += "  " + comment
+	}
+	if afterBrace != "" {
+		result = append(result, p.processLine(afterBrace)...)
+	}
+	return result, true
+}
+
+// bodyEnd returns the offset of the brace closing a block whose body starts
+// code, or -1 when the block does not close on this line
+func bodyEnd(code string) int {
+	depth := 0
+	for _, tok := range significantTokens(code) {
+		switch {
+		case tok.kind != tokenOp:
+		case isOpenBracket(tok.text):
+			depth++
+		case isCloseBracket(tok.text):
+			if depth == 0 {
+				if tok.text == "}" {
+					return tok.col
+				}
+				return -1
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// closeDoLoop handles the "} while cond;" that closes a do loop: the body
+// ends with "if not (cond): break" and the same check is inserted before
+// every continue of the loop so the condition is still evaluated. body holds
+// the lines of a loop written on one line, which are not yet pending.
+func (p *PythonPreprocessor) closeDoLoop(trimmed string, body []string) []string {
+	frame := p.loops[len(p.loops)-1]
+	remaining := strings.TrimSpace(trimmed[1:])
+
+	code, comment := splitTrailingComment(remaining)
+	cond, ok := doCondition(code)
+	check := "break"
+	if ok {
+		check = "if not (" + cond + "): break"
+		remaining = ""
+	} else {
+		p.warn("do loop closed without a 'while' condition")
+		comment = ""
+	}
+	p.pending = expandContinues(p.pending, frame.id, check)
+	result := expandContinues(body, frame.id, check)
+	if comment != "" {
+		check += "  " + comment
+	}
+
+	result = append(result, p.indent()+check)
+	p.loops = p.loops[:len(p.loops)-1]
+	p.indentLevel--
+	p.structuralBlocks--
+
+	if remaining != "" {
+		result = append(result, p.processLine(remaining)...)
+	}
+	return result
+}
+
+// doCondition extracts the condition of "while cond;", without its
+// semicolon or a pair of parentheses around the whole of it
+func doCondition(code string) (string, bool) {
+	if !strings.HasPrefix(code, "while") || (len(code) > len("while") && isNameChar(code[len("while")])) {
+		return "", false
+	}
+	cond := strings.TrimSpace(strings.TrimSuffix(code[len("while"):], ";"))
+
+	tokens := significantTokens(cond)
+	if len(tokens) == 0 {
+		return "", false
+	}
+	if tokens[0].text == "(" && closingBracket(tokens, 0) == len(tokens)-1 && tokens[len(tokens)-1].text == ")" {
+		cond = strings.TrimSpace(cond[1 : len(cond)-1])
+	}
+	return cond, cond != ""
+}
+
+// continueMarker tags a pending continue of the do loop with the given id
+func continueMarker(id int) string {
+	return fmt.Sprintf("\x00do%d\x00", id)
+}
+
+// markContinue tags a continue statement that belongs to a do loop so that
+// its condition check can be inserted once the loop closes
+func (p *PythonPreprocessor) markContinue(stmt string) string {
+	if len(p.loops) == 0 {
+		return stmt
+	}
+	frame := p.loops[len(p.loops)-1]
+	if code, _ := splitTrailingComment(stmt); !frame.do || code != "continue" {
+		return stmt
+	}
+	return continueMarker(frame.id) + stmt
+}
+
+// expandContinues puts check before each held back continue of the do loop
+// with the given id in lines; an empty check only removes the markers
+func expandContinues(lines []string, id int, check string) []string {
+	marker := continueMarker(id)
+	expanded := lines[:0:0]
+	for _, line := range lines {
+		at := strings.Index(line, marker)
+		if at == -1 {
+			expanded = append(expanded, line)
+			continue
+		}
+		indentation := line[:at]
+		if check != "" {
+			expanded = append(expanded, indentation+check)
+		}
+		expanded = append(expanded, indentation+line[at+len(marker):])
+	}
+	return expanded
+}
+
+// holdOutput reports whether output is being held back for an open do loop
+// or switch
+func (p *PythonPreprocessor) holdOutput() bool {
+	if len(p.switches) > 0 {
+		return true
+	}
+	for _, frame := range p.loops {
+		if frame.do {
+			return true
+		}
+	}
+	return false
+}
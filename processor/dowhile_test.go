@@ -0,0 +1,153 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoWhile(t *testing.T) {
+	//given
+	input := `i = 0
+do {
+    i += 1;
+    print(i);
+} while (i < 3);
+print("done")`
+
+	expected := `i = 0
+while True:
+  i += 1
+  print(i)
+  if not (i < 3): break
+print("done")
+`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Diagnostics())
+	assert.Empty(t, p.Verify(input, result))
+}
+
+func TestDoWhileContinue(t *testing.T) {
+	//given
+	input := `do {
+    n = next_item()
+    if n is None { continue }
+    for x in n.parts {
+        if not x {
+            continue
+        }
+        handle(x)
+    }
+    if n.skip {
+        continue  # skip the rest
+    }
+    do {
+        n = retry(n)
+    } while n.failed  # inner
+    total += n.size
+} while total < limit;`
+
+	expected := `while True:
+  n = next_item()
+  if n is None:
+    if not (total < limit): break
+    continue
+  for x in n.parts:
+    if not x:
+      continue
+    handle(x)
+  if n.skip:
+    if not (total < limit): break
+    continue  # skip the rest
+  while True:
+    n = retry(n)
+    if not (n.failed): break  # inner
+  total += n.size
+  if not (total < limit): break
+`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Verify(input, result))
+}
+
+func TestDoWhileOnOneLine(t *testing.T) {
+	//given
+	input := `do { a() } while b;
+do { n = step(n) } while n < 3  # retry
+do { continue } while waiting()
+switch mode {
+    case 1 {
+        do { poll() } while busy();
+    }
+}
+after()`
+
+	expected := `while True:
+  a()
+  if not (b): break
+while True:
+  n = step(n)
+  if not (n < 3): break  # retry
+while True:
+  if not (waiting()): break
+  continue
+  if not (waiting()): break
+_switch_1 = mode
+if _switch_1 == 1:
+  while True:
+    poll()
+    if not (busy()): break
+after()
+`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Diagnostics())
+	assert.Empty(t, p.Verify(input, result))
+}
+
+func TestDoWithoutWhile(t *testing.T) {
+	//given
+	input := `do {
+    step()
+}
+after()`
+
+	expected := `while True:
+  step()
+  break
+after()
+`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Len(t, p.Diagnostics(), 1)
+	assert.Contains(t, p.Diagnostics()[0].Message, "without a 'while' condition")
+}
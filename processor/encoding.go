@@ -0,0 +1,207 @@
+package processor
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+// codingCookie matches a PEP 263 encoding declaration, which must be a
+// comment on the first or second line
+var codingCookie = regexp.MustCompile(`^[ \t\f]*#.*?coding[:=][ \t]*([-\w.]+)`)
+
+// binarySniffLength is how much of a file is searched for NUL bytes when
+// deciding whether it is binary
+const binarySniffLength = 8000
+
+// pythonCodecs maps the Python names of codecs that the IANA registry knows
+// under another name
+var pythonCodecs = map[string]string{
+	"utf8": "utf-8", "u8": "utf-8", "utf": "utf-8", "utf-8-sig": "utf-8", "cp65001": "utf-8",
+	"ascii": "us-ascii", "646": "us-ascii",
+	"latin": "iso-8859-1", "iso-latin-1": "iso-8859-1", "8859": "iso-8859-1",
+	"latin9":    "iso-8859-15",
+	"shift-jis": "shift_jis", "sjis": "shift_jis", "s-jis": "shift_jis", "shiftjis": "shift_jis",
+	"cp932": "shift_jis", "ms932": "shift_jis", "mskanji": "shift_jis", "ms-kanji": "shift_jis",
+	"eucjp": "euc-jp", "ujis": "euc-jp", "u-jis": "euc-jp",
+	"euckr": "euc-kr", "korean": "euc-kr", "ksc5601": "euc-kr", "ks-c-5601": "euc-kr", "cp949": "euc-kr", "uhc": "euc-kr",
+	"gb2312": "gbk", "chinese": "gbk", "euc-cn": "gbk", "euccn": "gbk", "936": "gbk", "ms936": "gbk",
+	"big5-tw": "big5", "csbig5": "big5", "cp950": "big5",
+	"iso2022-jp": "iso-2022-jp", "csiso2022jp": "iso-2022-jp",
+	"mac-roman": "macintosh", "macroman": "macintosh", "mac-cyrillic": "x-mac-cyrillic",
+}
+
+// charset is the encoding a source declares; a nil *charset is UTF-8
+type charset struct {
+	name     string
+	encoding encoding.Encoding
+}
+
+// lookupCharset finds an encoding by its Python name, normalised the way
+// Python does and allowing the Emacs style suffixes of "latin-1-unix". It
+// returns nil for UTF-8 and false for encodings that are not supported,
+// including those that are not ASCII compatible and so cannot encode Python
+// source.
+func lookupCharset(name string) (*charset, bool) {
+	normalized := strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+	for _, suffix := range []string{"-unix", "-dos", "-mac"} {
+		normalized = strings.TrimSuffix(normalized, suffix)
+	}
+
+	candidates := []string{normalized, strings.ReplaceAll(normalized, "-", "")}
+	if alias, ok := pythonCodecs[normalized]; ok {
+		candidates = append([]string{alias}, candidates...)
+	}
+	switch {
+	case strings.HasPrefix(normalized, "cp125"):
+		candidates = append(candidates, "windows-"+normalized[len("cp"):])
+	case strings.HasPrefix(normalized, "iso8859-"):
+		candidates = append(candidates, "iso-8859-"+normalized[len("iso8859-"):])
+	}
+
+	for _, candidate := range candidates {
+		enc, err := ianaindex.IANA.Encoding(candidate)
+		if err != nil || enc == nil {
+			continue
+		}
+		if iana, _ := ianaindex.IANA.Name(enc); iana == "UTF-8" {
+			return nil, true
+		}
+		// Python source must keep ASCII as it is
+		if probe, err := enc.NewEncoder().String("#\n"); err != nil || probe != "#\n" {
+			return nil, false
+		}
+		return &charset{name: name, encoding: enc}, true
+	}
+	return nil, false
+}
+
+// codingDeclaration returns the encoding declared by source and the line
+// declaring it, or "" when there is none
+func codingDeclaration(source []byte) (string, int) {
+	source = bytes.TrimPrefix(source, []byte(byteOrderMark))
+	for line := 1; line <= 2 && len(source) > 0; line++ {
+		text, rest, _ := bytes.Cut(source, []byte("\n"))
+		if m := codingCookie.FindSubmatch(text); m != nil {
+			return string(m[1]), line
+		}
+		// the second line only counts after a comment or blank line
+		if trimmed := bytes.TrimSpace(text); len(trimmed) > 0 && trimmed[0] != '#' {
+			break
+		}
+		source = rest
+	}
+	return "", 0
+}
+
+// decode returns source as UTF-8 text with bytes the encoding does not
+// define replaced by U+FFFD, and the line of the first one or 0
+func (c *charset) decode(source []byte) (string, int) {
+	decoded, err := c.encoding.NewDecoder().Bytes(source)
+	if err != nil {
+		return string(source), 1
+	}
+	text := string(decoded)
+	if i := strings.IndexRune(text, utf8.RuneError); i != -1 {
+		return text, strings.Count(text[:i], "\n") + 1
+	}
+	return text, 0
+}
+
+// encode returns text in the encoding, writing characters it cannot
+// represent as '?', and the first of those or -1
+func (c *charset) encode(text string) ([]byte, rune) {
+	if encoded, err := c.encoding.NewEncoder().String(text); err == nil {
+		return []byte(encoded), -1
+	}
+
+	var out []byte
+	bad := rune(-1)
+	encoder := c.encoding.NewEncoder()
+	for _, r := range text {
+		encoded, err := encoder.String(string(r))
+		if err != nil {
+			if bad == -1 {
+				bad = r
+			}
+			encoded = "?"
+		}
+		out = append(out, encoded...)
+	}
+	return out, bad
+}
+
+// DecodeSource returns the text of a Python source file, decoding it from
+// the encoding its PEP 263 declaration names. Sources without one, or naming
+// an encoding that is not supported, are returned as they are.
+func DecodeSource(source []byte) string {
+	name, _ := codingDeclaration(source)
+	c, _ := lookupCharset(name)
+	if c == nil || bytes.HasPrefix(source, []byte(byteOrderMark)) {
+		return string(source)
+	}
+	text, _ := c.decode(source)
+	return text
+}
+
+// decodeSource is DecodeSource reporting undefined bytes, unsupported
+// encodings, a byte order mark contradicting the declaration and undeclared
+// non-UTF-8 input. It returns the charset to encode the output with, nil for
+// UTF-8.
+func (p *PythonPreprocessor) decodeSource(source []byte) (string, *charset) {
+	savedLine := p.lineNumber
+	defer func() { p.lineNumber = savedLine }()
+
+	name, line := codingDeclaration(source)
+	c, ok := lookupCharset(name)
+	switch {
+	case name == "":
+		if !utf8.Valid(source) {
+			p.lineNumber = 0
+			p.warn("source is not valid UTF-8 and declares no encoding")
+		}
+		return string(source), nil
+	case !ok:
+		p.lineNumber = line
+		p.warn("unsupported source encoding '%s'; reading the file as UTF-8", name)
+		return string(source), nil
+	case c != nil && bytes.HasPrefix(source, []byte(byteOrderMark)):
+		p.lineNumber = line
+		p.warn("UTF-8 byte order mark conflicts with declared encoding '%s'; reading the file as UTF-8", name)
+		return string(source), nil
+	case c == nil:
+		return string(source), nil
+	}
+
+	text, bad := c.decode(source)
+	if bad != 0 {
+		p.lineNumber = bad
+		p.warn("bytes not defined in %s replaced with U+FFFD", c.name)
+	}
+	return text, c
+}
+
+// encodeOutput encodes output in the charset of its source, reporting
+// characters the charset cannot represent
+func (p *PythonPreprocessor) encodeOutput(output string, c *charset) []byte {
+	if c == nil {
+		return []byte(output)
+	}
+	encoded, bad := c.encode(output)
+	if bad != -1 {
+		savedLine := p.lineNumber
+		p.lineNumber = 0
+		p.warn("character %U cannot be encoded in %s; written as '?'", bad, c.name)
+		p.lineNumber = savedLine
+	}
+	return encoded
+}
+
+// isBinary reports whether source looks like a binary file rather than text
+func isBinary(source []byte) bool {
+	return bytes.IndexByte(source[:min(len(source), binarySniffLength)], 0) != -1
+}
@@ -0,0 +1,205 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodingDeclaration(t *testing.T) {
+	//given
+	tests := []struct {
+		name     string
+		source   string
+		encoding string
+		line     int
+	}{
+		{"emacs style", "# -*- coding: latin-1 -*-\nx = 1\n", "latin-1", 1},
+		{"vim style", "# vim: set fileencoding=cp1252 :\n", "cp1252", 1},
+		{"after shebang", "#!/usr/bin/env python3\n# coding=iso-8859-15\n", "iso-8859-15", 2},
+		{"after code", "x = 1\n# coding: latin-1\n", "", 0},
+		{"third line", "#\n#\n# coding: latin-1\n", "", 0},
+		{"not a comment", "s = 'coding: latin-1'\n", "", 0},
+		{"none", "x = 1\n", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//when
+			encoding, line := codingDeclaration([]byte(tt.source))
+
+			//then
+			assert.Equal(t, tt.encoding, encoding)
+			assert.Equal(t, tt.line, line)
+		})
+	}
+}
+
+func TestLookupCharset(t *testing.T) {
+	//given
+	tests := []struct {
+		name      string
+		supported bool
+		utf8      bool
+	}{
+		{"utf-8", true, true},
+		{"UTF8", true, true},
+		{"utf-8-unix", true, true},
+		{"Latin_1", true, false},
+		{"iso8859_1", true, false},
+		{"latin-1-dos", true, false},
+		{"cp1251", true, false},
+		{"windows-1252", true, false},
+		{"latin9", true, false},
+		{"shift_jis", true, false},
+		{"gbk", true, false},
+		{"koi8-r", true, false},
+		{"euc-kr", true, false},
+		{"ascii", true, false},
+		{"utf-16", false, false},
+		{"klingon", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//when
+			c, ok := lookupCharset(tt.name)
+
+			//then
+			assert.Equal(t, tt.supported, ok)
+			assert.Equal(t, tt.supported && !tt.utf8, c != nil)
+		})
+	}
+}
+
+func TestProcessFileEncodings(t *testing.T) {
+	//given
+	tests := []struct {
+		name        string
+		input       string
+		expected    string
+		diagnostics []string
+	}{
+		{"latin-1",
+			"# -*- coding: latin-1 -*-\nif x {\n    s = 'caf\xe9'\n}\n",
+			"# -*- coding: latin-1 -*-\nif x:\n    s = 'caf\xe9'\n", nil},
+		{"cp1252",
+			"# coding: cp1252\nif x {\n    print('\x93caf\xe9\x94')\n}\n",
+			"# coding: cp1252\nif x:\n    print('\x93caf\xe9\x94')\n", nil},
+		{"cp1251",
+			"# coding: cp1251\nif x {\n    s = '\xcf\xf0\xe8\xe2\xe5\xf2'\n}\n",
+			"# coding: cp1251\nif x:\n    s = '\xcf\xf0\xe8\xe2\xe5\xf2'\n", nil},
+		{"shift_jis",
+			"# -*- coding: shift_jis -*-\nif x {\n    s = '\x93\xfa\x96\x7b'\n}\n",
+			"# -*- coding: shift_jis -*-\nif x:\n    s = '\x93\xfa\x96\x7b'\n", nil},
+		{"undefined byte",
+			"# coding: ascii\n\ns = '\xe9'\n",
+			"# coding: ascii\n\ns = '?'\n",
+			[]string{"bytes not defined in ascii replaced with U+FFFD", "character U+FFFD cannot be encoded in ascii; written as '?'"}},
+		{"unsupported encoding",
+			"# coding: klingon\ns = '\xe9'\n",
+			"# coding: klingon\ns = '\xe9'\n",
+			[]string{"unsupported source encoding 'klingon'; reading the file as UTF-8"}},
+		{"bom with latin-1",
+			"\ufeff# coding: latin-1\nx = 1\n",
+			"\ufeff# coding: latin-1\nx = 1\n",
+			[]string{"UTF-8 byte order mark conflicts with declared encoding 'latin-1'; reading the file as UTF-8"}},
+		{"undeclared non-utf-8",
+			"s = 'caf\xe9'\n",
+			"s = 'caf\xe9'\n",
+			[]string{"source is not valid UTF-8 and declares no encoding"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			inputPath := filepath.Join(dir, "input.by")
+			outputPath := filepath.Join(dir, "output.py")
+			writeFiles(t, dir, map[string]string{"input.by": tt.input})
+			p := NewPythonPreprocessor(4)
+
+			//when
+			err := p.ProcessFile(inputPath, outputPath)
+
+			//then
+			assert.NoError(t, err)
+			output, err := os.ReadFile(outputPath)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(output))
+			var messages []string
+			for _, d := range p.Diagnostics() {
+				messages = append(messages, d.Message)
+			}
+			assert.Equal(t, tt.diagnostics, messages)
+		})
+	}
+}
+
+func TestProcessFileStrictEncoding(t *testing.T) {
+	//given
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"input.by": "# coding: latin-1\n#!include \"names.by\"\n",
+		"names.by": "name = '\u4e2d'\n",
+	})
+	p := NewPythonPreprocessorWithOptions(WithStrict(true))
+
+	//when
+	err := p.ProcessFile(filepath.Join(dir, "input.by"), filepath.Join(dir, "output.py"))
+
+	//then
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "character U+4E2D cannot be encoded in latin-1")
+	}
+}
+
+func TestIncludeDecodesIncludedFile(t *testing.T) {
+	//given
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.by":  "# coding: utf-8\n#!include \"names.by\"\n",
+		"names.by": "# coding: latin-1\nname = 'Jos\xe9'\n",
+	})
+	p := NewPythonPreprocessor(4)
+	outputPath := filepath.Join(dir, "main.py")
+
+	//when
+	err := p.ProcessFile(filepath.Join(dir, "main.by"), outputPath)
+
+	//then
+	assert.NoError(t, err)
+	assert.Empty(t, p.Diagnostics())
+	output, err := os.ReadFile(outputPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "# coding: utf-8\n# coding: latin-1\nname = 'José'\n", string(output))
+}
+
+func TestFolderProcessorEncodings(t *testing.T) {
+	//given
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	writeFiles(t, tmpDir, map[string]string{
+		"input/brace.py":    "# coding: latin-1\nif x {\n    y('\xe9')\n}\n",
+		"input/standard.py": "# coding: latin-1\nif x:\n    y('\xe9')\n",
+		"input/binary.py":   "\x00\x01\x02 {\n",
+	})
+	fp := NewFolderProcessor(4, "*.py", 2)
+	fp.SetVerify(true)
+
+	//when
+	err := fp.ProcessFolder(inputDir, outputDir)
+
+	//then
+	assert.NoError(t, err)
+	brace, err := os.ReadFile(filepath.Join(outputDir, "brace.py"))
+	assert.NoError(t, err)
+	assert.Equal(t, "# coding: latin-1\nif x:\n    y('\xe9')\n", string(brace))
+	standard, err := os.ReadFile(filepath.Join(outputDir, "standard.py"))
+	assert.NoError(t, err)
+	assert.Equal(t, "# coding: latin-1\nif x:\n    y('\xe9')\n", string(standard))
+	assert.NoFileExists(t, filepath.Join(outputDir, "binary.py"))
+	assert.Equal(t, []Diagnostic{{File: filepath.Join(inputDir, "binary.py"), Message: "binary file skipped"}}, fp.Diagnostics())
+}
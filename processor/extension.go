@@ -0,0 +1,75 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Extension is a set of opt-in syntax extensions
+type Extension uint
+
+const (
+	// ExtHybrid accepts colon-indented blocks inside brace files
+	ExtHybrid Extension = 1 << iota
+	// ExtLogicalOperators translates &&, || and ! into and, or and not
+	ExtLogicalOperators
+	// ExtLiteralAliases translates true, false and null into True, False and None
+	ExtLiteralAliases
+	// ExtNilAlias translates nil into None
+	ExtNilAlias
+	// ExtCComments converts // and /* */ comments into # comments
+	ExtCComments
+	// ExtTernary translates cond ? a : b into a if cond else b
+	ExtTernary
+	// ExtIncrement translates x++ and x-- statements into x += 1 and x -= 1
+	ExtIncrement
+)
+
+var extensionNames = map[string]Extension{
+	"hybrid":      ExtHybrid,
+	"logical-ops": ExtLogicalOperators,
+	"literals":    ExtLiteralAliases,
+	"nil":         ExtNilAlias,
+	"c-comments":  ExtCComments,
+	"ternary":     ExtTernary,
+	"increment":   ExtIncrement,
+}
+
+// ParseExtensions parses a comma separated list of extension names
+func ParseExtensions(list string) (Extension, error) {
+	var ext Extension
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		e, ok := extensionNames[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown extension '%s'", name)
+		}
+		ext |= e
+	}
+	return ext, nil
+}
+
+func (e Extension) String() string {
+	var names []string
+	for name, ext := range extensionNames {
+		if e&ext != 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// ExtensionNames lists the names accepted by ParseExtensions
+func ExtensionNames() []string {
+	names := make([]string, 0, len(extensionNames))
+	for name := range extensionNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,20 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseExtensions(t *testing.T) {
+	//when
+	ext, err := ParseExtensions(" hybrid ,")
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, ExtHybrid, ext)
+	assert.Equal(t, "hybrid", ext.String())
+
+	_, err = ParseExtensions("hybrid,bogus")
+	assert.EqualError(t, err, "unknown extension 'bogus'")
+}
@@ -4,31 +4,135 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 )
 
 type FolderProcessor struct {
-	indentSize  int
+	options     Options
 	filePattern string
 	workers     int
+	verify      bool
+
+	mu           sync.Mutex
+	diagnostics  []Diagnostic
+	dependencies map[string][]string
 }
 
 func NewFolderProcessor(indentSize int, filePattern string, workers int) *FolderProcessor {
+	return NewFolderProcessorWithOptions(filePattern, workers, WithIndentSize(indentSize))
+}
+
+// NewFolderProcessorWithOptions creates a folder processor whose files are
+// converted with DefaultOptions changed by opts
+func NewFolderProcessorWithOptions(filePattern string, workers int, opts ...Option) *FolderProcessor {
 	if workers <= 0 {
 		workers = 4
 	}
-	if indentSize <= 0 {
-		indentSize = 4
-	}
 	return &FolderProcessor{
-		indentSize:  indentSize,
+		options:     newOptions(opts),
 		filePattern: filePattern,
 		workers:     workers,
 	}
 }
 
+// EnableExtensions turns on the given opt-in syntax extensions for every file
+func (f *FolderProcessor) EnableExtensions(ext Extension) {
+	WithExtensions(ext)(&f.options)
+}
+
+// Define sets a flag for the #!if conditions of every file
+func (f *FolderProcessor) Define(name, value string) {
+	WithDefine(name, value)(&f.options)
+}
+
+// AddIncludePath adds a directory searched for #!include files
+func (f *FolderProcessor) AddIncludePath(dir string) {
+	WithIncludePath(dir)(&f.options)
+}
+
+// SetVerify makes ProcessFolder check every output against its source with
+// Verify, reporting divergences through Diagnostics
+func (f *FolderProcessor) SetVerify(verify bool) {
+	f.verify = verify
+}
+
+// Diagnostics returns the diagnostics gathered by the last ProcessFolder run,
+// ordered by file and line
+func (f *FolderProcessor) Diagnostics() []Diagnostic {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	diagnostics := append([]Diagnostic(nil), f.diagnostics...)
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].File != diagnostics[j].File {
+			return diagnostics[i].File < diagnostics[j].File
+		}
+		return diagnostics[i].Line < diagnostics[j].Line
+	})
+	return diagnostics
+}
+
+// Dependencies maps each output written by the last ProcessFolder run to the
+// files it was built from: its source followed by the files it includes
+func (f *FolderProcessor) Dependencies() map[string][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dependencies := make(map[string][]string, len(f.dependencies))
+	for output, inputs := range f.dependencies {
+		dependencies[output] = append([]string(nil), inputs...)
+	}
+	return dependencies
+}
+
+// Dependents returns the outputs of the last ProcessFolder run that were
+// built from path, either as their source or through #!include, so that a
+// change to path can rebuild exactly those
+func (f *FolderProcessor) Dependents(path string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var outputs []string
+	for output, inputs := range f.dependencies {
+		for _, input := range inputs {
+			if filepath.Clean(input) == filepath.Clean(path) || sameFile(input, path) {
+				outputs = append(outputs, output)
+				break
+			}
+		}
+	}
+	sort.Strings(outputs)
+	return outputs
+}
+
+func (f *FolderProcessor) report(file string, diagnostics ...Diagnostic) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, d := range diagnostics {
+		// diagnostics inside included files already name them
+		if d.File == "" {
+			d.File = file
+		}
+		f.diagnostics = append(f.diagnostics, d)
+	}
+}
+
+func (f *FolderProcessor) depend(outputPath, inputPath string, includes []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.dependencies[outputPath] = append([]string{inputPath}, includes...)
+}
+
 func (f *FolderProcessor) ProcessFolder(inputDir, outputDir string) error {
+	f.mu.Lock()
+	f.diagnostics = nil
+	f.dependencies = make(map[string][]string)
+	f.mu.Unlock()
+
 	files, err := f.discoverFiles(inputDir)
 	if err != nil {
 		return err
@@ -84,7 +188,7 @@ func (f *FolderProcessor) processFilesConcurrently(inputDir, outputDir string, f
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			localProcessor := NewPythonPreprocessor(f.indentSize)
+			localProcessor := NewPythonPreprocessorWithOptions(WithOptions(f.options))
 			for file := range jobs {
 				relPath, err := filepath.Rel(inputDir, file)
 				if err != nil {
@@ -102,7 +206,7 @@ func (f *FolderProcessor) processFilesConcurrently(inputDir, outputDir string, f
 
 				outputPath = strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".py"
 
-				if err := localProcessor.ProcessFile(file, outputPath); err != nil {
+				if err := f.processFile(localProcessor, file, outputPath); err != nil {
 					results <- result{file, err}
 					continue
 				}
@@ -139,3 +243,112 @@ func (f *FolderProcessor) processFilesConcurrently(inputDir, outputDir string, f
 
 	return nil
 }
+
+// processFile converts a brace-style file and copies standard Python through
+// unchanged, reporting files that mix both styles
+func (f *FolderProcessor) processFile(p *PythonPreprocessor, inputPath, outputPath string) error {
+	source, err := os.ReadFile(inputPath)
+	if err != nil {
+		return fmt.Errorf("error reading input file: %v", err)
+	}
+
+	if isBinary(source) {
+		f.report(inputPath, Diagnostic{Message: "binary file skipped"})
+		return nil
+	}
+
+	p.reset()
+	p.file = inputPath
+	text, encoding := p.decodeSource(source)
+
+	// the style is that of the source after conditionals and includes, and
+	// syntax extensions do not apply to standard Python
+	extensions := p.extensions
+	p.extensions = 0
+	expanded, origins, problems := p.expandSource(inputPath, strings.TrimPrefix(text, byteOrderMark))
+	p.extensions = extensions
+	origin := func(line int) sourcePos {
+		if line < 1 || line > len(origins) {
+			return sourcePos{inputPath, line}
+		}
+		return origins[line-1]
+	}
+
+	report := DetectStyle(expanded)
+	if report.Style == StyleStandard && report.ColonLine == 0 && p.rewritesTokens(inputPath, text, expanded) {
+		report.Style = StyleBrace
+	}
+	switch report.Style {
+	case StyleStandard:
+		p.diagnostics = append(p.diagnostics, problems...)
+		output := p.formatText(expanded, detectFormat(text))
+		if err := os.WriteFile(outputPath, p.encodeOutput(output, encoding), 0644); err != nil {
+			return fmt.Errorf("error creating output file: %v", err)
+		}
+		if err := p.copyFileInfo(inputPath, outputPath); err != nil {
+			return fmt.Errorf("error copying file mode: %v", err)
+		}
+		f.depend(outputPath, inputPath, p.Includes())
+		if p.strict && len(p.diagnostics) > 0 {
+			return p.strictError()
+		}
+		f.report(inputPath, p.Diagnostics()...)
+		return nil
+	case StyleMixed:
+		if f.options.Extensions&ExtHybrid == 0 {
+			colon := origin(report.ColonLine)
+			f.report(inputPath, Diagnostic{
+				File: colon.file,
+				Line: colon.line,
+				Message: fmt.Sprintf("colon-indented block mixed with brace blocks (first on line %d); enable the hybrid extension to convert it",
+					origin(report.BraceLine).line),
+			})
+		}
+	}
+
+	err = p.ProcessFile(inputPath, outputPath)
+	f.depend(outputPath, inputPath, p.Includes())
+	if err != nil {
+		return err
+	}
+	f.report(inputPath, p.Diagnostics()...)
+
+	if f.verify {
+		return f.verifyOutput(p, inputPath, outputPath)
+	}
+	return nil
+}
+
+// rewritesTokens reports whether the enabled syntax extensions change the
+// tokens of source, expanded being source expanded without them; standard
+// Python never needs them, so such a file is written in brace style
+func (p *PythonPreprocessor) rewritesTokens(file, source, expanded string) bool {
+	if p.extensions&rewriteExtensions() == 0 {
+		return false
+	}
+	rewritten, _, _ := p.expandSource(file, strings.TrimPrefix(source, byteOrderMark))
+	a, b := significantTokens(expanded), significantTokens(rewritten)
+	if len(a) != len(b) {
+		return true
+	}
+	for i := range a {
+		if a[i].text != b[i].text {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FolderProcessor) verifyOutput(p *PythonPreprocessor, inputPath, outputPath string) error {
+	source, err := os.ReadFile(inputPath)
+	if err != nil {
+		return fmt.Errorf("error reading input file: %v", err)
+	}
+	output, err := os.ReadFile(outputPath)
+	if err != nil {
+		return fmt.Errorf("error reading output file: %v", err)
+	}
+
+	f.report(inputPath, p.Verify(DecodeSource(source), DecodeSource(output))...)
+	return nil
+}
@@ -105,3 +105,163 @@ func TestFolderProcessorWithPattern(t *testing.T) {
 	ignoredFile := filepath.Join(outputDir, "ignore.py")
 	assert.NoFileExists(t, ignoredFile, "Expected ignore.py to NOT be created")
 }
+
+func TestFolderProcessorDetectsStyle(t *testing.T) {
+	//given
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	standard := `def foo(items):
+    return {
+        "count": len(items),
+    }
+`
+
+	files := map[string]string{
+		"brace.py":    "if x {\n    print(\"brace\");\n}",
+		"standard.py": standard,
+		"mixed.py":    "if x {\n    y();\n}\ndef z():\n    pass\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(inputDir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fp := NewFolderProcessor(2, "*.py", 2)
+
+	//when
+	err := fp.ProcessFolder(inputDir, outputDir)
+
+	//then
+	assert.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "brace.py"))
+	assert.NoError(t, err)
+	assert.Equal(t, "if x:\n  print(\"brace\")\n", string(content))
+
+	content, err = os.ReadFile(filepath.Join(outputDir, "standard.py"))
+	assert.NoError(t, err)
+	assert.Equal(t, standard, string(content))
+
+	diagnostics := fp.Diagnostics()
+	assert.Len(t, diagnostics, 1)
+	assert.Equal(t, filepath.Join(inputDir, "mixed.py"), diagnostics[0].File)
+	assert.Equal(t, 4, diagnostics[0].Line)
+	assert.Contains(t, diagnostics[0].Message, "mixed with brace blocks (first on line 1)")
+}
+
+func TestFolderProcessorBraceFileWithoutBlocks(t *testing.T) {
+	//given
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	standard := "import sys\nx = not sys.argv  # no blocks\n"
+	writeFiles(t, tmpDir, map[string]string{
+		"input/semicolons.py": "x = a && !b;\ny = true;\nprint(x ? 1 : 2);\n",
+		"input/extensions.py": "done = x && y\n",
+		"input/standard.py":   standard,
+	})
+	fp := NewFolderProcessorWithOptions("*.py", 2, WithExtensions(ExtLogicalOperators|ExtLiteralAliases|ExtTernary))
+
+	//when
+	err := fp.ProcessFolder(inputDir, outputDir)
+
+	//then
+	assert.NoError(t, err)
+	assert.Empty(t, fp.Diagnostics())
+	for name, expected := range map[string]string{
+		"semicolons.py": "x = a and not b\ny = True\nprint(1 if x else 2)\n",
+		"extensions.py": "done = x and y\n",
+		"standard.py":   standard,
+	} {
+		content, err := os.ReadFile(filepath.Join(outputDir, name))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(content), name)
+	}
+}
+
+func TestFolderProcessorDependencies(t *testing.T) {
+	//given
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	includeDir := filepath.Join(tmpDir, "include")
+
+	writeFiles(t, tmpDir, map[string]string{
+		"input/a.py":        "#!include \"shared.py\"\nif x {\n    y()\n}",
+		"input/b.py":        "if z {\n    w()\n}",
+		"input/c.py":        "#!include \"shared.py\"\nimport os\n",
+		"include/shared.py": "import sys",
+	})
+
+	fp := NewFolderProcessor(2, "*.py", 2)
+	fp.AddIncludePath(includeDir)
+
+	//when
+	err := fp.ProcessFolder(inputDir, outputDir)
+
+	//then
+	assert.NoError(t, err)
+	shared := filepath.Join(includeDir, "shared.py")
+	outA, outC := filepath.Join(outputDir, "a.py"), filepath.Join(outputDir, "c.py")
+
+	assert.Equal(t, []string{filepath.Join(inputDir, "a.py"), shared}, fp.Dependencies()[outA])
+	assert.Equal(t, []string{filepath.Join(inputDir, "b.py")}, fp.Dependencies()[filepath.Join(outputDir, "b.py")])
+	assert.Equal(t, []string{outA, outC}, fp.Dependents(shared))
+
+	content, _ := os.ReadFile(outC)
+	assert.Equal(t, "import sys\nimport os\n", string(content))
+}
+
+func TestFolderProcessorStandardFileDiagnostics(t *testing.T) {
+	//given
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	writeFiles(t, tmpDir, map[string]string{
+		"input/missing.py":  "#!include \"gone.py\"\nimport os\n",
+		"input/unclosed.py": "#!if DEBUG\nimport pdb\n",
+		"input/includes.py": "#!include \"../braces.by\"\nimport os\n",
+		"braces.by":         "if x {\n    y()\n}\n",
+	})
+	fp := NewFolderProcessor(4, "*.py", 2)
+
+	//when
+	err := fp.ProcessFolder(inputDir, outputDir)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, []Diagnostic{
+		{File: filepath.Join(inputDir, "missing.py"), Line: 1, Message: "include file 'gone.py' not found"},
+		{File: filepath.Join(inputDir, "unclosed.py"), Line: 2, Message: "'#!if' not closed at end of input"},
+	}, fp.Diagnostics())
+	content, err := os.ReadFile(filepath.Join(outputDir, "includes.py"))
+	assert.NoError(t, err)
+	assert.Equal(t, "if x:\n    y()\nimport os\n", string(content))
+}
+
+func TestFolderProcessorStandardFileStrict(t *testing.T) {
+	//given
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	writeFiles(t, tmpDir, map[string]string{
+		"input/missing.py": "#!include \"gone.py\"\nimport os\n",
+	})
+	fp := NewFolderProcessorWithOptions("*.py", 2, WithStrict(true))
+
+	//when
+	err := fp.ProcessFolder(inputDir, outputDir)
+
+	//then
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "include file 'gone.py' not found")
+}
@@ -0,0 +1,137 @@
+package processor
+
+import "strings"
+
+// isHybridHeader reports whether a line opens a colon-indented block
+func (p *PythonPreprocessor) isHybridHeader(trimmed string) bool {
+	code, _ := splitTrailingComment(trimmed)
+	return strings.HasSuffix(code, ":") && p.isControlStatement(code)
+}
+
+// hybridFrame is a colon-indented block suspended while a brace block inside
+// it holds another one
+type hybridFrame struct {
+	indents []int
+	pending bool
+	blocks  int
+}
+
+// startHybridBlock emits a colon-terminated header and starts tracking the
+// source indentation of the block that follows it
+func (p *PythonPreprocessor) startHybridBlock(line, trimmed string) []string {
+	if len(p.hybridIndents) > 0 {
+		p.hybridOuter = append(p.hybridOuter, hybridFrame{p.hybridIndents, p.hybridPending, p.hybridBlocks})
+		p.hybridIndents = nil
+	}
+	p.hybridIndents = append(p.hybridIndents[:0], p.indentColumns(line))
+	p.hybridPending = true
+	p.hybridBlocks = p.structuralBlocks
+	return []string{p.indent() + trimmed}
+}
+
+// endHybridBlock stops tracking the innermost colon-indented block, resuming
+// the one around the brace block it was in
+func (p *PythonPreprocessor) endHybridBlock() {
+	p.hybridIndents = p.hybridIndents[:0]
+	if n := len(p.hybridOuter); n > 0 {
+		outer := p.hybridOuter[n-1]
+		p.hybridOuter = p.hybridOuter[:n-1]
+		p.hybridIndents, p.hybridPending, p.hybridBlocks = outer.indents, outer.pending, outer.blocks
+	}
+}
+
+// processHybridLine re-indents a line of a colon-indented block relative to
+// the surrounding brace nesting. It returns false once the line dedents to or
+// past the block's header, or opens a brace block, leaving it to be processed
+// as brace-style code.
+func (p *PythonPreprocessor) processHybridLine(line, trimmed string) ([]string, bool) {
+	// the inside of a multi-line string is part of its value
+	if p.inString {
+		return []string{line}, true
+	}
+
+	col := p.indentColumns(line)
+	top := p.hybridIndents[len(p.hybridIndents)-1]
+
+	// comments follow their own indentation without opening or closing levels
+	if strings.HasPrefix(trimmed, "#") {
+		level := p.indentLevel
+		if p.hybridPending && col > top {
+			level++
+		}
+		for i := len(p.hybridIndents) - 1; i > 0 && col < p.hybridIndents[i]; i-- {
+			level--
+		}
+		return []string{strings.Repeat(p.indentChar, level) + trimmed}, true
+	}
+
+	if p.hybridPending && col > top {
+		p.hybridIndents = append(p.hybridIndents, col)
+		p.indentLevel++
+		top = col
+	}
+	p.hybridPending = false
+
+	for len(p.hybridIndents) > 1 && col < top {
+		p.hybridIndents = p.hybridIndents[:len(p.hybridIndents)-1]
+		p.indentLevel--
+		top = p.hybridIndents[len(p.hybridIndents)-1]
+	}
+
+	if len(p.hybridIndents) == 1 {
+		if col <= top {
+			p.endHybridBlock()
+			return nil, false
+		}
+		// inconsistent dedent; treat the line as opening a new level
+		p.hybridIndents = append(p.hybridIndents, col)
+		p.indentLevel++
+		top = col
+	}
+
+	code, _ := splitTrailingComment(trimmed)
+	if brace := p.findStructuralBrace(code); brace != -1 && p.isControlStatement(strings.TrimSpace(code[:brace])) {
+		return nil, false
+	}
+
+	processedLine := p.indent() + strings.Repeat(" ", col-top) + trimmed
+
+	if strings.HasSuffix(code, ":") {
+		p.hybridPending = true
+	}
+
+	return []string{processedLine}, true
+}
+
+// leadingWidth returns the number of whitespace characters indenting line
+func leadingWidth(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// indentColumns returns the column the text of line starts at, with a tab
+// advancing to the next multiple of the tab width
+func (p *PythonPreprocessor) indentColumns(line string) int {
+	col := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ' ':
+			col++
+		case '\t':
+			col += p.tabWidth - col%p.tabWidth
+		default:
+			return col
+		}
+	}
+	return col
+}
+
+// splitTrailingComment separates a trailing # comment from the code before
+// it, ignoring # characters inside string literals
+func splitTrailingComment(line string) (code, comment string) {
+	for _, tok := range tokenize(line) {
+		if tok.kind == tokenComment {
+			return strings.TrimRight(line[:tok.col], " \t"), tok.text
+		}
+	}
+	return line, ""
+}
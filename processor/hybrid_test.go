@@ -0,0 +1,191 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHybridBlockInsideBraceClass(t *testing.T) {
+	//given
+	input := `class Foo {
+    def helper(self, items):
+        # vendored helper
+        for i in items:
+            if i:
+                print(i)
+            else:
+                pass
+        return 1
+
+    def other(self) {
+        return 2;
+    }
+}`
+
+	expected := `class Foo:
+  def helper(self, items):
+    # vendored helper
+    for i in items:
+      if i:
+        print(i)
+      else:
+        pass
+    return 1
+
+  def other(self):
+    return 2
+`
+
+	p := NewPythonPreprocessor(2)
+	p.EnableExtensions(ExtHybrid)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Verify(input, result))
+}
+
+func TestHybridBlockAtTopLevel(t *testing.T) {
+	//given
+	input := `def first() {
+    return 1;
+}
+
+def second():
+  value = first()
+  return value
+
+if __name__ == "__main__" {
+    print(second());
+}`
+
+	expected := `def first():
+    return 1
+
+def second():
+    value = first()
+    return value
+
+if __name__ == "__main__":
+    print(second())
+`
+
+	p := NewPythonPreprocessor(4)
+	p.EnableExtensions(ExtHybrid)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Verify(input, result))
+}
+
+func TestHybridBlockClosedByBrace(t *testing.T) {
+	//given
+	input := `for x in xs {
+    if x:
+        print(x)
+}
+done();`
+
+	expected := `for x in xs:
+  if x:
+    print(x)
+done()
+`
+
+	p := NewPythonPreprocessor(2)
+	p.EnableExtensions(ExtHybrid)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Verify(input, result))
+}
+
+func TestBraceBlockInsideHybridBlock(t *testing.T) {
+	//given
+	input := `class Foo {
+    def f(self, x):
+        if x {
+            y();
+        } else {
+            for i in x:
+                z(i)
+            w();
+        }
+        return x
+
+    def g(self) {
+        return 2;
+    }
+}`
+
+	expected := `class Foo:
+  def f(self, x):
+    if x:
+      y()
+    else:
+      for i in x:
+        z(i)
+      w()
+    return x
+
+  def g(self):
+    return 2
+`
+
+	p := NewPythonPreprocessor(2)
+	p.EnableExtensions(ExtHybrid)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Diagnostics())
+	assert.Empty(t, p.Verify(input, result))
+}
+
+func TestHybridBlockWithMultilineString(t *testing.T) {
+	//given
+	input := `class Foo {
+    def usage(self):
+        s = """
+first
+  second
+"""
+        return s
+}`
+
+	expected := `class Foo:
+    def usage(self):
+        s = """
+first
+  second
+"""
+        return s
+`
+
+	p := NewPythonPreprocessor(4)
+	p.EnableExtensions(ExtHybrid)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Diagnostics())
+	assert.Empty(t, p.Verify(input, result))
+}
@@ -0,0 +1,206 @@
+package processor
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sourceFrame is a file being read; included files are pushed on top of the
+// file that includes them, which is resumed at file and line once they end
+type sourceFrame struct {
+	scanner *bufio.Scanner
+	file    string
+	line    int
+}
+
+// sourcePos is the file and line a line of expanded source came from
+type sourcePos struct {
+	file string
+	line int
+}
+
+// AddIncludePath adds a directory searched for #!include files that are not
+// found next to the file including them
+func (p *PythonPreprocessor) AddIncludePath(dir string) {
+	p.includePaths = append(p.includePaths, dir)
+}
+
+// Includes returns the files included while processing the last input, in
+// the order they were first read
+func (p *PythonPreprocessor) Includes() []string {
+	return p.includes
+}
+
+// nextLine returns the next line of input, continuing with the including
+// file at the end of an included one
+func (p *PythonPreprocessor) nextLine() (string, bool, error) {
+	for len(p.sources) > 0 {
+		top := p.sources[len(p.sources)-1]
+		if top.scanner.Scan() {
+			p.lineNumber++
+			return top.scanner.Text(), true, nil
+		}
+		if len(p.sources) == 1 {
+			return "", false, top.scanner.Err()
+		}
+		if err := top.scanner.Err(); err != nil {
+			p.warn("error reading included file: %v", err)
+		}
+		p.popInclude()
+	}
+	return "", false, nil
+}
+
+// include handles #!include "path", reading the file's lines next as if
+// they were written in place of the directive
+func (p *PythonPreprocessor) include(args string) {
+	name, err := strconv.Unquote(args)
+	if err != nil || name == "" {
+		p.warn("malformed include '%s'; expected #!include \"path\"", args)
+		return
+	}
+
+	path, ok := p.resolveInclude(name)
+	if !ok {
+		p.warn("include file '%s' not found", name)
+		return
+	}
+
+	// the files being read, innermost first
+	open := []string{p.file}
+	for i := len(p.sources) - 1; i > 0; i-- {
+		open = append(open, p.sources[i].file)
+	}
+	for k, file := range open {
+		if sameFile(file, path) {
+			var chain []string
+			for i := k; i >= 0; i-- {
+				chain = append(chain, open[i])
+			}
+			p.warn("include cycle: %s -> %s", strings.Join(chain, " -> "), path)
+			return
+		}
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		p.warn("cannot include '%s': %v", name, err)
+		return
+	}
+
+	frame := sourceFrame{file: p.file, line: p.lineNumber}
+	p.file, p.lineNumber = path, 0
+	text, _ := p.decodeSource(source)
+	frame.scanner = bufio.NewScanner(strings.NewReader(strings.TrimPrefix(text, byteOrderMark)))
+	p.sources = append(p.sources, frame)
+	for _, seen := range p.includes {
+		if seen == path {
+			return
+		}
+	}
+	p.includes = append(p.includes, path)
+}
+
+// popInclude ends the innermost included file
+func (p *PythonPreprocessor) popInclude() {
+	top := p.sources[len(p.sources)-1]
+	p.sources = p.sources[:len(p.sources)-1]
+	p.file, p.lineNumber = top.file, top.line
+}
+
+// closeIncludes ends all included files, as when input stops with an error
+func (p *PythonPreprocessor) closeIncludes() {
+	for len(p.sources) > 1 {
+		p.popInclude()
+	}
+	p.sources = p.sources[:0]
+}
+
+// resolveInclude looks for name next to the including file and then in the
+// include paths
+func (p *PythonPreprocessor) resolveInclude(name string) (string, bool) {
+	if filepath.IsAbs(name) {
+		return name, isRegularFile(name)
+	}
+	dirs := append([]string{filepath.Dir(p.file)}, p.includePaths...)
+	for _, dir := range dirs {
+		if path := filepath.Join(dir, name); isRegularFile(path) {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+func isRegularFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+func sameFile(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
+
+// expandSource applies conditional compilation, includes, macros and the
+// enabled syntax extensions to the source of file, returning the lines that
+// remain together with where each came from and the problems found on the
+// way. Processing state and diagnostics are left as they were.
+func (p *PythonPreprocessor) expandSource(file, source string) (string, []sourcePos, []Diagnostic) {
+	p.includes = nil
+	if !strings.Contains(source, directivePrefix) && p.extensions&rewriteExtensions() == 0 {
+		return source, nil, nil
+	}
+
+	savedFile, savedLine, diagnostics, macros, names := p.file, p.lineNumber, p.diagnostics, p.macros, p.names
+	defer func() {
+		p.closeIncludes()
+		p.conditionals = p.conditionals[:0]
+		p.verbatim, p.openQuote, p.inBlockComment = false, "", false
+		p.file, p.lineNumber, p.diagnostics, p.macros, p.names = savedFile, savedLine, diagnostics, macros, names
+	}()
+
+	p.file, p.lineNumber = file, 0
+	p.conditionals = p.conditionals[:0]
+	p.macros, p.names = nil, nil
+	p.diagnostics = nil
+	p.verbatim, p.openQuote, p.inBlockComment = false, "", false
+	p.sources = append(p.sources[:0], sourceFrame{scanner: bufio.NewScanner(strings.NewReader(source))})
+
+	var lines []string
+	var origins []sourcePos
+	for {
+		line, ok, _ := p.nextLine()
+		if !ok {
+			break
+		}
+		if p.skipDirective(line) {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case isPragma(trimmed, "off"):
+			p.verbatim = true
+		case isPragma(trimmed, "on"):
+			p.verbatim = false
+		default:
+			line = p.rewriteLine(line)
+		}
+		lines = append(lines, line)
+		origins = append(origins, sourcePos{p.file, p.lineNumber})
+	}
+	if len(p.conditionals) > 0 {
+		p.warn("'#!if' not closed at end of input")
+	}
+	expanded := strings.Join(lines, "\n")
+	if len(lines) > 0 && strings.HasSuffix(source, "\n") {
+		expanded += "\n"
+	}
+	return expanded, origins, p.diagnostics
+}
@@ -0,0 +1,99 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		fullPath := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestInclude(t *testing.T) {
+	//given
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/app.py": `#!include "helpers.py"
+def main() {
+    #!include "body.py"
+    done();
+}`,
+		"src/body.py": `if ready {
+    go();
+}`,
+		"lib/helpers.py": `def go() {
+    pass;
+}`,
+	})
+	input := filepath.Join(dir, "src", "app.py")
+	output := filepath.Join(dir, "app.py")
+
+	p := NewPythonPreprocessor(2)
+	p.AddIncludePath(filepath.Join(dir, "lib"))
+
+	//when
+	err := p.ProcessFile(input, output)
+
+	//then
+	assert.NoError(t, err)
+	result, _ := os.ReadFile(output)
+	assert.Equal(t, `def go():
+  pass
+def main():
+  if ready:
+    go()
+  done()
+`, string(result))
+	assert.Equal(t, []string{filepath.Join(dir, "lib", "helpers.py"), filepath.Join(dir, "src", "body.py")}, p.Includes())
+	assert.Empty(t, p.Diagnostics())
+
+	source, _ := os.ReadFile(input)
+	assert.Empty(t, p.Verify(string(source), string(result)))
+}
+
+func TestIncludeDiagnostics(t *testing.T) {
+	//given
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.py": `#!include "b.py"
+#!include "missing.py"
+x = 1`,
+		"b.py": `y = 2
+}
+#!include "a.py"`,
+	})
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	err := p.ProcessFile(filepath.Join(dir, "a.py"), filepath.Join(dir, "out.py"))
+
+	//then
+	assert.NoError(t, err)
+	diagnostics := p.Diagnostics()
+	assert.Len(t, diagnostics, 3)
+
+	assert.Equal(t, filepath.Join(dir, "b.py"), diagnostics[0].File)
+	assert.Equal(t, 2, diagnostics[0].Line)
+	assert.Equal(t, "unmatched closing brace", diagnostics[0].Message)
+
+	assert.Equal(t, filepath.Join(dir, "b.py"), diagnostics[1].File)
+	assert.Equal(t, 3, diagnostics[1].Line)
+	assert.Contains(t, diagnostics[1].Message, "include cycle")
+
+	assert.Equal(t, filepath.Join(dir, "a.py"), diagnostics[2].File)
+	assert.Equal(t, 2, diagnostics[2].Line)
+	assert.Equal(t, "include file 'missing.py' not found", diagnostics[2].Message)
+}
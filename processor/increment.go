@@ -0,0 +1,186 @@
+package processor
+
+import "strings"
+
+// rewriteIncrements translates the statements x++, x--, ++x and --x into
+// x += 1 and x -= 1. The target may be a name with attribute and subscript
+// trailers. A postfix increment anywhere but in a statement of its own is
+// reported, since Python has no expression with the same meaning, and so is
+// an increment of something that cannot be assigned to, such as f(a)++.
+func (p *PythonPreprocessor) rewriteIncrements(code string) string {
+	if !strings.Contains(code, "++") && !strings.Contains(code, "--") {
+		return code
+	}
+
+	tokens := significantTokens(code)
+	var edits []edit
+
+	for i := 0; i+1 < len(tokens); i++ {
+		op := tokens[i].text
+		if op != "+" && op != "-" || !isOpPair(tokens, i, op) {
+			continue
+		}
+		assign := " " + op + "= 1"
+
+		// prefix form: ++x as a statement of its own
+		if startsStatement(tokens, i) {
+			if end := targetEnd(tokens, i+2); end > i+2 && tokens[end-1].text != ")" && endsStatement(tokens, end) {
+				target := code[tokens[i+2].col : tokens[end-1].col+len(tokens[end-1].text)]
+				edits = append(edits, edit{tokens[i].col, tokens[end-1].col + len(tokens[end-1].text), target + assign})
+				i = end - 1
+			} else if i+2 < len(tokens) && !isUnaryPrefix(tokens[i+2]) {
+				if end := operandEnd(tokens, i+2); end > i+2 && endsStatement(tokens, end) {
+					operand := code[tokens[i+2].col : tokens[end-1].col+len(tokens[end-1].text)]
+					p.warn("'%s%s%s' cannot be rewritten since '%s' cannot be assigned to", op, op, operand, operand)
+					i = end - 1
+				}
+			}
+			continue
+		}
+
+		start := targetStart(tokens, i)
+		if !endsIncrement(tokens, i+2) {
+			// x--y subtracts, but x++ + 1 is written as a postfix increment
+			postfix := !startsOperand(tokens[i+2]) || adjacent(tokens[i-1], tokens[i]) && !adjacent(tokens[i+1], tokens[i+2])
+			if start != -1 && tokens[i-1].text != ")" && postfix {
+				target := code[tokens[start].col : tokens[i-1].col+len(tokens[i-1].text)]
+				p.warn("'%s%s%s' is only supported as a statement of its own; Python has no increment expression", target, op, op)
+				i++
+			}
+			continue
+		}
+		if start == -1 || tokens[i-1].text == ")" {
+			if operand := operandStart(tokens, i); operand != -1 {
+				target := code[tokens[operand].col : tokens[i-1].col+len(tokens[i-1].text)]
+				p.warn("'%s%s%s' cannot be rewritten since '%s' cannot be assigned to", target, op, op, target)
+				i++
+			}
+			continue
+		}
+		target := code[tokens[start].col : tokens[i-1].col+len(tokens[i-1].text)]
+		if !startsStatement(tokens, start) || !endsStatement(tokens, i+2) {
+			p.warn("'%s%s%s' is only supported as a statement of its own; Python has no increment expression", target, op, op)
+			i++
+			continue
+		}
+		edits = append(edits, edit{tokens[i-1].col + len(tokens[i-1].text), tokens[i+1].col + 1, assign})
+		i++
+	}
+
+	return applyEdits(code, edits)
+}
+
+// startsStatement reports whether a statement begins at tokens[i]
+func startsStatement(tokens []token, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev := tokens[i-1]
+	return prev.kind == tokenOp && (prev.text == ";" || prev.text == "{")
+}
+
+// endsStatement reports whether a statement ends just before tokens[i]
+func endsStatement(tokens []token, i int) bool {
+	if i >= len(tokens) {
+		return true
+	}
+	next := tokens[i]
+	return next.kind == tokenOp && (next.text == ";" || next.text == "}")
+}
+
+// endsIncrement reports whether a ++ or -- before tokens[i] is postfix;
+// anything that could start an operand makes it a binary operator followed
+// by a unary one instead, as in x--y
+func endsIncrement(tokens []token, i int) bool {
+	if i >= len(tokens) {
+		return true
+	}
+	next := tokens[i]
+	if next.kind != tokenOp {
+		return false
+	}
+	return isCloseBracket(next.text) || next.text == ";" || next.text == "," || next.text == ":"
+}
+
+// startsOperand reports whether an operand can begin at tok, which makes a
+// ++ or -- before it a binary operator followed by a unary one
+func startsOperand(tok token) bool {
+	switch tok.kind {
+	case tokenName:
+		return !pythonKeywords[tok.text] || tok.text == "not" || tok.text == "lambda" || tok.text == "await"
+	case tokenNumber, tokenString:
+		return true
+	}
+	return isOpenBracket(tok.text) || tok.text == "-" || tok.text == "+" || tok.text == "~" || tok.text == "!" || tok.text == "..."
+}
+
+// targetStart returns the index where the assignable target ending at
+// tokens[i-1] begins, or -1 when no target ends there
+func targetStart(tokens []token, i int) int {
+	j := i - 1
+	for j >= 0 {
+		for j >= 0 && tokens[j].kind == tokenOp && (tokens[j].text == "]" || tokens[j].text == ")") {
+			j = openingBracket(tokens, j) - 1
+		}
+		if j < 0 || tokens[j].kind != tokenName || pythonKeywords[tokens[j].text] {
+			return -1
+		}
+		if j > 0 && tokens[j-1].kind == tokenOp && tokens[j-1].text == "." {
+			j -= 2
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+// operandStart returns the index where the operand ending at tokens[i-1]
+// begins, with its attribute, call and subscript trailers, or -1 when no
+// operand ends there
+func operandStart(tokens []token, i int) int {
+	j := i - 1
+	for j >= 0 {
+		switch tok := tokens[j]; {
+		case tok.kind == tokenOp && isCloseBracket(tok.text):
+			if j = openingBracket(tokens, j); j == -1 {
+				return -1
+			}
+		case tok.kind == tokenString:
+			for j > 0 && tokens[j-1].kind == tokenString {
+				j--
+			}
+		case tok.kind != tokenName && tok.kind != tokenNumber:
+			return -1
+		}
+
+		switch {
+		case j >= 2 && tokens[j-1].kind == tokenOp && tokens[j-1].text == ".":
+			j -= 2
+		case (tokens[j].text == "(" || tokens[j].text == "[") && tokens[j].kind == tokenOp && j > 0 && endsOperand(tokens[j-1]):
+			j--
+		default:
+			return j
+		}
+	}
+	return -1
+}
+
+// endsOperand reports whether tok can be the last token of an operand
+func endsOperand(tok token) bool {
+	switch tok.kind {
+	case tokenName:
+		return !pythonKeywords[tok.text]
+	case tokenNumber, tokenString:
+		return true
+	}
+	return tok.kind == tokenOp && (tok.text == ")" || tok.text == "]")
+}
+
+// targetEnd returns the index just past the assignable target starting at
+// tokens[j], or j when none starts there
+func targetEnd(tokens []token, j int) int {
+	if j >= len(tokens) || tokens[j].kind != tokenName || pythonKeywords[tokens[j].text] {
+		return j
+	}
+	return operandEnd(tokens, j)
+}
@@ -0,0 +1,99 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrement(t *testing.T) {
+	//given
+	input := `i++;
+j--
+obj.count++  # one more
+arr[k]--;
+self.items[key].hits++
+--remaining;
+for x in xs { total++ }
+a = b--c
+s = "x++"`
+
+	expected := `i += 1
+j -= 1
+obj.count += 1  # one more
+arr[k] -= 1
+self.items[key].hits += 1
+remaining -= 1
+for x in xs:
+  total += 1
+a = b--c
+s = "x++"
+`
+
+	p := NewPythonPreprocessor(2)
+	p.EnableExtensions(ExtIncrement)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Diagnostics())
+	assert.Empty(t, p.Verify(input, result))
+}
+
+func TestIncrementInExpression(t *testing.T) {
+	//given
+	input := `y = x++
+f(n--, 2)
+z = x++ + 1
+w = a.b++ if c else 0
+v = x++ -1`
+
+	p := NewPythonPreprocessor(2)
+	p.EnableExtensions(ExtIncrement)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, "y = x++\nf(n--, 2)\nz = x++ + 1\nw = a.b++ if c else 0\nv = x++ -1\n", result)
+	diagnostics := p.Diagnostics()
+	assert.Len(t, diagnostics, 5)
+	assert.Equal(t, 1, diagnostics[0].Line)
+	assert.Contains(t, diagnostics[0].Message, "'x++' is only supported as a statement")
+	assert.Equal(t, 2, diagnostics[1].Line)
+	assert.Contains(t, diagnostics[1].Message, "'n--'")
+	assert.Equal(t, 3, diagnostics[2].Line)
+	assert.Contains(t, diagnostics[2].Message, "'x++' is only supported as a statement")
+	assert.Equal(t, 4, diagnostics[3].Line)
+	assert.Contains(t, diagnostics[3].Message, "'a.b++'")
+	assert.Equal(t, 5, diagnostics[4].Line)
+}
+
+func TestIncrementOfUnassignableTarget(t *testing.T) {
+	//given
+	input := `f(a)++
+obj.items()[0]--
+3--
+++g(b)
+x = 1 + 2
+total++`
+
+	p := NewPythonPreprocessor(2)
+	p.EnableExtensions(ExtIncrement)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, "f(a)++\nobj.items()[0] -= 1\n3--\n++g(b)\nx = 1 + 2\ntotal += 1\n", result)
+	assert.Equal(t, []Diagnostic{
+		{Line: 1, Message: "'f(a)++' cannot be rewritten since 'f(a)' cannot be assigned to"},
+		{Line: 3, Message: "'3--' cannot be rewritten since '3' cannot be assigned to"},
+		{Line: 4, Message: "'++g(b)' cannot be rewritten since 'g(b)' cannot be assigned to"},
+	}, p.Diagnostics())
+}
@@ -0,0 +1,75 @@
+package processor
+
+import "strings"
+
+var literalAliases = map[string]string{
+	"true":  "True",
+	"false": "False",
+	"null":  "None",
+}
+
+// rewriteLiteralAliases translates the bare identifiers true, false and null,
+// and nil when ExtNilAlias is enabled, into True, False and None. Attribute
+// names, keyword argument names and other binding positions are left alone.
+func (p *PythonPreprocessor) rewriteLiteralAliases(code string) string {
+	if !containsAlias(code, p.extensions) {
+		return code
+	}
+
+	tokens := tokenize(code)
+	var edits []edit
+
+	for i, tok := range tokens {
+		if tok.kind != tokenName {
+			continue
+		}
+		replacement, ok := p.literalAlias(tok.text)
+		if !ok || isBindingPosition(tokens, i) {
+			continue
+		}
+		edits = append(edits, edit{tok.col, tok.col + len(tok.text), replacement})
+	}
+
+	return applyEdits(code, edits)
+}
+
+func (p *PythonPreprocessor) literalAlias(name string) (string, bool) {
+	if name == "nil" {
+		return "None", p.extensions&ExtNilAlias != 0
+	}
+	replacement, ok := literalAliases[name]
+	return replacement, ok && p.extensions&ExtLiteralAliases != 0
+}
+
+func containsAlias(code string, ext Extension) bool {
+	if ext&ExtNilAlias != 0 && strings.Contains(code, "nil") {
+		return true
+	}
+	return ext&ExtLiteralAliases != 0 &&
+		(strings.Contains(code, "true") || strings.Contains(code, "false") || strings.Contains(code, "null"))
+}
+
+// isBindingPosition reports whether the name at tokens[i] is an attribute,
+// a keyword argument or parameter name, an assignment target, or is being
+// defined or imported, rather than used as a value
+func isBindingPosition(tokens []token, i int) bool {
+	if i > 0 {
+		prev := tokens[i-1]
+		if prev.kind == tokenOp && prev.text == "." {
+			return true
+		}
+		if prev.kind == tokenName {
+			switch prev.text {
+			case "def", "class", "import", "as", "global", "nonlocal":
+				return true
+			}
+		}
+	}
+	if i+1 < len(tokens) && tokens[i+1].kind == tokenOp {
+		switch tokens[i+1].text {
+		case "=", ":=":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiteralAliases(t *testing.T) {
+	//given
+	input := `def check(value=null, strict=false) {
+    if value == null || obj.true {
+        return configure(true=1, flag=true);
+    }
+    null = "not the literal";
+    return {"ok": true, "text": "true or false"}  # false stays in comments
+}`
+
+	expected := `def check(value=None, strict=False):
+  if value == None || obj.true:
+    return configure(true=1, flag=True)
+  null = "not the literal"
+  return {"ok": True, "text": "true or false"}  # false stays in comments
+`
+
+	p := NewPythonPreprocessor(2)
+	p.EnableExtensions(ExtLiteralAliases)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Verify(input, result))
+}
+
+func TestNilAlias(t *testing.T) {
+	//given
+	input := `x = nil;
+y = null;`
+
+	p := NewPythonPreprocessor(2)
+	p.EnableExtensions(ExtNilAlias)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, "x = None\ny = null\n", result)
+}
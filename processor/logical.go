@@ -0,0 +1,177 @@
+package processor
+
+import "strings"
+
+// boundaryKeywords may directly precede or follow a not-expression without
+// changing how it groups
+var boundaryKeywords = map[string]bool{
+	"and": true, "or": true, "not": true, "if": true, "elif": true, "else": true, "while": true,
+	"return": true, "yield": true, "assert": true, "for": true,
+}
+
+// rewriteLogicalOperators translates C-style &&, || and ! into and, or and
+// not. C's ! binds tighter than any binary operator while Python's not binds
+// looser, so a ! whose operand meets a binary operator is parenthesised.
+func (p *PythonPreprocessor) rewriteLogicalOperators(code string) string {
+	if !strings.ContainsAny(code, "&|!") {
+		return code
+	}
+
+	tokens := tokenize(code)
+	var edits []edit
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.kind != tokenOp {
+			continue
+		}
+
+		switch {
+		case isOpPair(tokens, i, "&"):
+			edits = append(edits, edit{tok.col, tok.col + 2, spaced(code, tok.col, tok.col+2, "and")})
+			i++
+		case isOpPair(tokens, i, "|"):
+			edits = append(edits, edit{tok.col, tok.col + 2, spaced(code, tok.col, tok.col+2, "or")})
+			i++
+		case tok.text == "!":
+			edits = append(edits, notEdits(code, tokens, i)...)
+		}
+	}
+
+	return applyEdits(code, edits)
+}
+
+// notEdits rewrites the ! at tokens[i]
+func notEdits(code string, tokens []token, i int) []edit {
+	tok := tokens[i]
+	end := operandEnd(tokens, i+1)
+	nested := i > 0 && tokens[i-1].kind == tokenOp && tokens[i-1].text == "!"
+
+	word := "not"
+	if next := tok.col + 1; next < len(code) && code[next] != ' ' && code[next] != '\t' {
+		word += " "
+	}
+
+	if nested {
+		return []edit{{tok.col, tok.col + 1, word}}
+	}
+	if end == i+1 || (opensExpression(tokens, i) && closesExpression(tokens, end)) {
+		return []edit{{tok.col, tok.col + 1, spaced(code, tok.col, tok.col+1, "not")}}
+	}
+
+	last := tokens[end-1]
+	return []edit{
+		{tok.col, tok.col, "("},
+		{tok.col, tok.col + 1, word},
+		{last.col + len(last.text), last.col + len(last.text), ")"},
+	}
+}
+
+// operandEnd returns the index just past the unary operand starting at
+// tokens[j]: any prefix operators, an atom, and its attribute, call and
+// subscript trailers
+func operandEnd(tokens []token, j int) int {
+	for j < len(tokens) && isUnaryPrefix(tokens[j]) {
+		j++
+	}
+	if j >= len(tokens) {
+		return j
+	}
+
+	switch tok := tokens[j]; {
+	case tok.kind == tokenOp && isOpenBracket(tok.text):
+		if j = closingBracket(tokens, j); j == -1 {
+			return len(tokens)
+		}
+		j++
+	case tok.kind == tokenName || tok.kind == tokenNumber:
+		j++
+	case tok.kind == tokenString:
+		for j < len(tokens) && tokens[j].kind == tokenString {
+			j++
+		}
+	default:
+		return j
+	}
+
+	for j < len(tokens) && tokens[j].kind == tokenOp {
+		switch {
+		case tokens[j].text == "." && j+1 < len(tokens) && tokens[j+1].kind == tokenName:
+			j += 2
+		case tokens[j].text == "(" || tokens[j].text == "[":
+			if j = closingBracket(tokens, j); j == -1 {
+				return len(tokens)
+			}
+			j++
+		default:
+			return j
+		}
+	}
+	return j
+}
+
+func isUnaryPrefix(tok token) bool {
+	if tok.kind == tokenName {
+		return tok.text == "not" || tok.text == "await"
+	}
+	return tok.kind == tokenOp && (tok.text == "!" || tok.text == "-" || tok.text == "+" || tok.text == "~")
+}
+
+// isAssignment reports whether op is = or an augmented assignment
+func isAssignment(op string) bool {
+	switch op {
+	case "==", "<=", ">=", "!=":
+		return false
+	}
+	return strings.HasSuffix(op, "=")
+}
+
+// opensExpression reports whether the token before tokens[i] lets a not
+// expression start there without parentheses
+func opensExpression(tokens []token, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev := tokens[i-1]
+	switch prev.kind {
+	case tokenName:
+		return boundaryKeywords[prev.text]
+	case tokenOp:
+		if isOpenBracket(prev.text) || isAssignment(prev.text) {
+			return true
+		}
+		switch prev.text {
+		case ",", ":", ";", "->", ":=":
+			return true
+		case "&", "|":
+			return i > 1 && isOpPair(tokens, i-2, prev.text)
+		}
+	}
+	return false
+}
+
+// closesExpression reports whether tokens[end] ends the not expression that
+// precedes it without parentheses
+func closesExpression(tokens []token, end int) bool {
+	if end >= len(tokens) {
+		return true
+	}
+	next := tokens[end]
+	switch next.kind {
+	case tokenComment, tokenNewline:
+		return true
+	case tokenName:
+		return next.text == "and" || next.text == "or" || next.text == "if" || next.text == "else" || next.text == "for"
+	case tokenOp:
+		if isCloseBracket(next.text) || isAssignment(next.text) || next.text == "{" {
+			return true
+		}
+		switch next.text {
+		case ",", ":", ";", "?":
+			return true
+		case "&", "|":
+			return isOpPair(tokens, end, next.text)
+		}
+	}
+	return false
+}
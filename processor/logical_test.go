@@ -0,0 +1,94 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogicalOperators(t *testing.T) {
+	//given
+	input := `if a && !b || c&&d {
+    ok = !done && x != y;
+    print(f"{x!r} && {y}", "a || b")  # && stays in comments
+}`
+
+	expected := `if a and not b or c and d:
+  ok = not done and x != y
+  print(f"{x!r} && {y}", "a || b")  # && stays in comments
+`
+
+	p := NewPythonPreprocessor(2)
+	p.EnableExtensions(ExtLogicalOperators)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Verify(input, result))
+}
+
+func TestLogicalNotPrecedence(t *testing.T) {
+	//given
+	input := `a = !x == y;
+b = z == !x.ready();
+c = !!flag;
+d = !(p || q)[0] + 1;`
+
+	expected := `a = (not x) == y
+b = z == (not x.ready())
+c = not not flag
+d = (not (p or q)[0]) + 1
+`
+
+	p := NewPythonPreprocessor(2)
+	p.EnableExtensions(ExtLogicalOperators)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+}
+
+func TestLogicalOperatorsSkipMultilineStrings(t *testing.T) {
+	//given
+	input := `def f() {
+    """Returns a && b
+    or !c"""
+    return a && b;
+}`
+
+	expected := `def f():
+  """Returns a && b
+  or !c"""
+  return a and b
+`
+
+	p := NewPythonPreprocessor(2)
+	p.EnableExtensions(ExtLogicalOperators)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+}
+
+func TestLogicalOperatorsDisabledByDefault(t *testing.T) {
+	//given
+	input := `x = a && b;`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, "x = a && b\n", result)
+}
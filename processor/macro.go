@@ -0,0 +1,182 @@
+package processor
+
+import "strings"
+
+// macro is a #!define; a function-like macro is called with arguments that
+// replace its parameters in the body
+type macro struct {
+	function bool
+	params   []string
+	body     string
+}
+
+func (m macro) equal(other macro) bool {
+	return m.function == other.function && m.body == other.body &&
+		strings.Join(m.params, ",") == strings.Join(other.params, ",")
+}
+
+// defineMacro handles "#!define NAME body" and "#!define NAME(a, b) body". A
+// macro is function-like only when the parenthesis directly follows its name.
+func (p *PythonPreprocessor) defineMacro(args string) {
+	end := 0
+	for end < len(args) && isNameChar(args[end]) {
+		end++
+	}
+	name, rest := args[:end], args[end:]
+	if name == "" || isDigit(name[0]) {
+		p.warn("malformed define '%s'; expected #!define NAME value", args)
+		return
+	}
+
+	var m macro
+	if strings.HasPrefix(rest, "(") {
+		close := strings.IndexByte(rest, ')')
+		if close == -1 {
+			p.warn("missing ')' in parameters of macro '%s'", name)
+			return
+		}
+		m.function = true
+		if params := strings.TrimSpace(rest[1:close]); params != "" {
+			for _, param := range strings.Split(params, ",") {
+				param = strings.TrimSpace(param)
+				if !isIdentifier(param) {
+					p.warn("invalid parameter '%s' in macro '%s'", param, name)
+					return
+				}
+				m.params = append(m.params, param)
+			}
+		}
+		rest = rest[close+1:]
+	}
+	m.body = strings.TrimSpace(rest)
+
+	if old, ok := p.macros[name]; ok && !old.equal(m) {
+		p.warn("macro '%s' redefined", name)
+	}
+	if p.macros == nil {
+		p.macros = make(map[string]macro)
+	}
+	p.macros[name] = m
+}
+
+// expandMacros replaces the macros used in a line of code. Only names are
+// replaced, never text inside string literals or comments, and attribute
+// names are left alone.
+func (p *PythonPreprocessor) expandMacros(code string) string {
+	return p.expand(code, nil)
+}
+
+// expand replaces the macros in code except those in disabled, which are
+// being expanded already, so a macro using its own name ends the expansion
+func (p *PythonPreprocessor) expand(code string, disabled map[string]bool) string {
+	tokens := tokenize(code)
+	var edits []edit
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.kind != tokenName || disabled[tok.text] {
+			continue
+		}
+		m, ok := p.macros[tok.text]
+		if !ok || (i > 0 && tokens[i-1].kind == tokenOp && tokens[i-1].text == ".") {
+			continue
+		}
+		inner := withDisabled(disabled, tok.text)
+
+		if !m.function {
+			edits = append(edits, edit{tok.col, tok.col + len(tok.text), p.expand(m.body, inner)})
+			continue
+		}
+
+		// a function-like macro named without a call is an ordinary name
+		if i+1 >= len(tokens) || tokens[i+1].kind != tokenOp || tokens[i+1].text != "(" {
+			continue
+		}
+		close := closingBracket(tokens, i+1)
+		if close == -1 {
+			p.warn("unterminated call of macro '%s'", tok.text)
+			return applyEdits(code, edits)
+		}
+		args := macroArguments(code, tokens[i+2:close])
+		if len(args) != len(m.params) {
+			p.warn("macro '%s' expects %d argument(s), got %d", tok.text, len(m.params), len(args))
+			i = close
+			continue
+		}
+		for k := range args {
+			args[k] = p.expand(args[k], disabled)
+		}
+		body := substituteParams(m.body, m.params, args)
+		edits = append(edits, edit{tok.col, tokens[close].col + 1, p.expand(body, inner)})
+		i = close
+	}
+
+	return applyEdits(code, edits)
+}
+
+// substituteParams replaces the parameter names in body with the arguments
+func substituteParams(body string, params, args []string) string {
+	if len(params) == 0 {
+		return body
+	}
+	var edits []edit
+	for _, tok := range tokenize(body) {
+		if tok.kind != tokenName {
+			continue
+		}
+		for k, param := range params {
+			if tok.text == param {
+				edits = append(edits, edit{tok.col, tok.col + len(tok.text), args[k]})
+				break
+			}
+		}
+	}
+	return applyEdits(body, edits)
+}
+
+// macroArguments splits the tokens between the parentheses of a macro call
+// into the text of each argument
+func macroArguments(code string, tokens []token) []string {
+	if len(tokens) == 0 {
+		return nil
+	}
+	var args []string
+	depth, start := 0, tokens[0].col
+	for _, tok := range tokens {
+		if tok.kind != tokenOp {
+			continue
+		}
+		switch {
+		case isOpenBracket(tok.text):
+			depth++
+		case isCloseBracket(tok.text):
+			depth--
+		case tok.text == "," && depth == 0:
+			args = append(args, strings.TrimSpace(code[start:tok.col]))
+			start = tok.col + 1
+		}
+	}
+	last := tokens[len(tokens)-1]
+	return append(args, strings.TrimSpace(code[start:last.col+len(last.text)]))
+}
+
+func withDisabled(disabled map[string]bool, name string) map[string]bool {
+	inner := make(map[string]bool, len(disabled)+1)
+	for k := range disabled {
+		inner[k] = true
+	}
+	inner[name] = true
+	return inner
+}
+
+func isIdentifier(s string) bool {
+	if s == "" || isDigit(s[0]) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isNameChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMacroExpansion(t *testing.T) {
+	//given
+	input := `#!define LOG(x) logger.debug(x)
+#!define PAIR(a, b) (a, b)
+#!define RETRIES 3
+def run(value) {
+    LOG("LOG(x) stays") # RETRIES stays too
+    for i in range(RETRIES) {
+        LOG(PAIR(value, i))
+    }
+    self.RETRIES = RETRIES
+}`
+	expected := `def run(value):
+  logger.debug("LOG(x) stays") # RETRIES stays too
+  for i in range(3):
+    logger.debug((value, i))
+  self.RETRIES = 3
+`
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Diagnostics())
+	assert.Empty(t, p.Verify(input, result))
+}
+
+func TestMacroExpandsBeforeBraces(t *testing.T) {
+	//given
+	input := `#!define GUARD(cond) if not (cond) {
+#!define FEATURE 1
+#!if FEATURE
+GUARD(ready)
+    return
+}
+#!endif
+#!undef FEATURE
+x = FEATURE`
+	expected := "if not (ready):\n  return\nx = FEATURE\n"
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+}
+
+func TestMacroNotExpandedInCComments(t *testing.T) {
+	//given
+	input := `#!define LOG(x) logger.debug(x)
+#!define LIMIT 10
+def run(y) {
+    /* LOG(y) */
+    y = min(y, LIMIT) // at most LIMIT
+    /* LIMIT spans
+       LOG(y) lines */
+    LOG(y)
+}`
+	expected := `def run(y):
+  # LOG(y)
+  y = min(y, 10)  # at most LIMIT
+  # LIMIT spans
+  # LOG(y) lines
+  logger.debug(y)
+`
+	p := NewPythonPreprocessor(2)
+	p.EnableExtensions(ExtCComments)
+
+	//when
+	result, err := p.ProcessString(input)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Diagnostics())
+	assert.Empty(t, p.Verify(input, result))
+}
+
+func TestMacroSelfReference(t *testing.T) {
+	//given
+	input := "#!define count count + 1\nx = count"
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, "x = count + 1\n", result)
+}
+
+func TestMacroDiagnostics(t *testing.T) {
+	//given
+	input := `#!define 1BAD x
+#!define ONE(a) a
+#!define ONE(a) a + 1
+#!define F(a, b-c) a
+x = ONE(1, 2)
+y = ONE(1`
+	p := NewPythonPreprocessor(2)
+
+	//when
+	_, err := p.ProcessString(input)
+
+	//then
+	assert.NoError(t, err)
+	var messages []string
+	for _, d := range p.Diagnostics() {
+		messages = append(messages, d.Message)
+	}
+	assert.Equal(t, []string{
+		"malformed define '1BAD x'; expected #!define NAME value",
+		"macro 'ONE' redefined",
+		"invalid parameter 'b-c' in macro 'F'",
+		"macro 'ONE' expects 1 argument(s), got 2",
+		"unterminated call of macro 'ONE'",
+	}, messages)
+}
@@ -0,0 +1,154 @@
+package processor
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// byteOrderMark is the UTF-8 encoding of U+FEFF
+const byteOrderMark = "\ufeff"
+
+// NewlineStyle selects the line endings of the output
+type NewlineStyle int
+
+const (
+	// NewlinePreserve ends lines like the first line of the input, or with
+	// \n when the input has a single line
+	NewlinePreserve NewlineStyle = iota
+	// NewlineLF ends lines with \n
+	NewlineLF
+	// NewlineCRLF ends lines with \r\n
+	NewlineCRLF
+)
+
+var newlineStyles = map[string]NewlineStyle{
+	"preserve": NewlinePreserve,
+	"lf":       NewlineLF,
+	"crlf":     NewlineCRLF,
+}
+
+// ParseNewlineStyle parses "preserve", "lf" or "crlf"
+func ParseNewlineStyle(s string) (NewlineStyle, error) {
+	style, ok := newlineStyles[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown newline style '%s' (want preserve, lf or crlf)", s)
+	}
+	return style, nil
+}
+
+func (s NewlineStyle) String() string {
+	for name, style := range newlineStyles {
+		if style == s {
+			return name
+		}
+	}
+	return strconv.Itoa(int(s))
+}
+
+// FinalNewline controls whether the last line of the output ends with a newline
+type FinalNewline int
+
+const (
+	// FinalNewlineAlways ends the output with a newline
+	FinalNewlineAlways FinalNewline = iota
+	// FinalNewlinePreserve ends the output with a newline when the input
+	// ends with one
+	FinalNewlinePreserve
+	// FinalNewlineNever leaves the last line unterminated
+	FinalNewlineNever
+)
+
+var finalNewlines = map[string]FinalNewline{
+	"always":   FinalNewlineAlways,
+	"preserve": FinalNewlinePreserve,
+	"never":    FinalNewlineNever,
+}
+
+// ParseFinalNewline parses "always", "preserve" or "never"
+func ParseFinalNewline(s string) (FinalNewline, error) {
+	policy, ok := finalNewlines[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown final newline policy '%s' (want always, preserve or never)", s)
+	}
+	return policy, nil
+}
+
+func (f FinalNewline) String() string {
+	for name, policy := range finalNewlines {
+		if policy == f {
+			return name
+		}
+	}
+	return strconv.Itoa(int(f))
+}
+
+// lineFormat records how the input was encoded into lines
+type lineFormat struct {
+	bom          bool
+	crlf         bool // the first line ends with \r\n
+	sawNewline   bool
+	finalNewline bool
+}
+
+// detectFormat returns the line format of a whole source
+func detectFormat(source string) lineFormat {
+	var f lineFormat
+	f.bom = strings.HasPrefix(source, byteOrderMark)
+	if i := strings.IndexByte(source, '\n'); i != -1 {
+		f.sawNewline = true
+		f.crlf = i > 0 && source[i-1] == '\r'
+	}
+	f.finalNewline = strings.HasSuffix(source, "\n")
+	return f
+}
+
+// scanLines is bufio.ScanLines recording the line format of the input
+func (p *PythonPreprocessor) scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	advance, line, err := bufio.ScanLines(data, atEOF)
+	if advance > 0 {
+		ending := string(data[len(line):advance])
+		if !p.format.sawNewline && strings.HasSuffix(ending, "\n") {
+			p.format.sawNewline = true
+			p.format.crlf = ending == "\r\n"
+		}
+		p.format.finalNewline = strings.HasSuffix(ending, "\n")
+	}
+	return advance, line, err
+}
+
+// newline returns the line ending of the output for an input of format f
+func (p *PythonPreprocessor) newline(f lineFormat) string {
+	if p.newlineStyle == NewlineCRLF || (p.newlineStyle == NewlinePreserve && f.crlf) {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// endsWithNewline reports whether the output for an input of format f ends
+// with a newline
+func (p *PythonPreprocessor) endsWithNewline(f lineFormat) bool {
+	switch p.finalNewline {
+	case FinalNewlineNever:
+		return false
+	case FinalNewlinePreserve:
+		return f.finalNewline
+	}
+	return true
+}
+
+// formatText applies the line ending and byte order mark policies to text
+// produced for an input of format f, keeping its final newline as it is
+func (p *PythonPreprocessor) formatText(text string, f lineFormat) string {
+	text = strings.TrimPrefix(text, byteOrderMark)
+	if newline := p.newline(f); newline == "\n" {
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+	} else {
+		text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", newline)
+	}
+	if f.bom && !p.stripBOM {
+		text = byteOrderMark + text
+	}
+	return text
+}
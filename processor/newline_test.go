@@ -0,0 +1,75 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineEndings(t *testing.T) {
+	//given
+	tests := []struct {
+		name     string
+		opts     []Option
+		input    string
+		expected string
+	}{
+		{"preserve crlf", nil,
+			"if x {\r\n    y()\r\n}\r\n", "if x:\r\n    y()\r\n"},
+		{"preserve lf", nil,
+			"if x {\n    y()\n}", "if x:\n    y()\n"},
+		{"normalize to lf", []Option{WithNewline(NewlineLF)},
+			"if x {\r\n    y()\r\n}\r\n", "if x:\n    y()\n"},
+		{"normalize to crlf", []Option{WithNewline(NewlineCRLF)},
+			"if x {\n    y()\n}\n", "if x:\r\n    y()\r\n"},
+		{"final newline preserved", []Option{WithFinalNewline(FinalNewlinePreserve)},
+			"a = 1\r\nb = 2", "a = 1\r\nb = 2"},
+		{"final newline never", []Option{WithFinalNewline(FinalNewlineNever)},
+			"a = 1\nb = 2\n", "a = 1\nb = 2"},
+		{"bom kept", nil,
+			"\ufeff#!if 1\nx = 1\n#!endif\n", "\ufeffx = 1\n"},
+		{"bom stripped", []Option{WithStripBOM(true)},
+			"\ufeffx = 1\n", "x = 1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPythonPreprocessorWithOptions(tt.opts...)
+
+			//when
+			result, err := p.ProcessString(tt.input)
+
+			//then
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+			assert.Empty(t, p.Diagnostics())
+			assert.Empty(t, p.Verify(tt.input, result))
+		})
+	}
+}
+
+func TestFolderProcessorLineEndings(t *testing.T) {
+	//given
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	writeFiles(t, tmpDir, map[string]string{
+		"input/brace.py":    "\ufeffif x {\r\n    y()\r\n}\r\n",
+		"input/standard.py": "\ufeffif x:\r\n    y()",
+	})
+	fp := NewFolderProcessorWithOptions("*.py", 2, WithNewline(NewlineLF), WithStripBOM(true))
+
+	//when
+	err := fp.ProcessFolder(inputDir, outputDir)
+
+	//then
+	assert.NoError(t, err)
+	brace, err := os.ReadFile(filepath.Join(outputDir, "brace.py"))
+	assert.NoError(t, err)
+	assert.Equal(t, "if x:\n    y()\n", string(brace))
+	standard, err := os.ReadFile(filepath.Join(outputDir, "standard.py"))
+	assert.NoError(t, err)
+	assert.Equal(t, "if x:\n    y()", string(standard))
+}
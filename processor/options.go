@@ -0,0 +1,193 @@
+package processor
+
+import "strings"
+
+// Options configures a PythonPreprocessor. Settings from a "# bython:" header
+// pragma still override them for a single file.
+type Options struct {
+	// IndentSize is the number of spaces per output indentation level
+	IndentSize int
+	// Tabs indents the output with one tab per level instead of spaces
+	Tabs bool
+	// TabWidth is the number of columns a tab advances to in source lines
+	// whose indentation is kept relative, such as inside multi-line literals
+	TabWidth int
+	// Semicolons controls what happens to statement-terminating semicolons
+	Semicolons SemicolonMode
+	// Strict fails processing instead of warning when a problem is found
+	Strict bool
+	// Literals controls the layout of multi-line list, dict and set literals
+	Literals LiteralStyle
+	// TrailingCommas ends canonically laid out literals with a comma after
+	// the last element
+	TrailingCommas bool
+	// Newline selects the line endings of the output
+	Newline NewlineStyle
+	// FinalNewline controls whether the output ends with a newline
+	FinalNewline FinalNewline
+	// StripBOM drops a UTF-8 byte order mark instead of copying it from the
+	// input to the output
+	StripBOM bool
+	// PreserveModTime gives outputs the modification time of their input;
+	// the permissions of the input are always copied
+	PreserveModTime bool
+	// Extensions are the opt-in syntax extensions to enable
+	Extensions Extension
+	// Defines are the flags seen by #!if conditions
+	Defines map[string]string
+	// IncludePaths are searched for #!include files
+	IncludePaths []string
+}
+
+// Option changes one setting of Options
+type Option func(*Options)
+
+// DefaultOptions returns the settings used when no option changes them
+func DefaultOptions() Options {
+	return Options{IndentSize: 4, TabWidth: 8}
+}
+
+// WithOptions replaces all settings with options
+func WithOptions(options Options) Option {
+	return func(o *Options) {
+		*o = options
+	}
+}
+
+// WithIndentSize sets the number of spaces per indentation level
+func WithIndentSize(size int) Option {
+	return func(o *Options) {
+		o.IndentSize = size
+	}
+}
+
+// WithTabs makes the output indent with tabs
+func WithTabs(tabs bool) Option {
+	return func(o *Options) {
+		o.Tabs = tabs
+	}
+}
+
+// WithTabWidth sets the number of columns a tab advances to in source lines
+func WithTabWidth(width int) Option {
+	return func(o *Options) {
+		o.TabWidth = width
+	}
+}
+
+// WithSemicolons sets what happens to statement-terminating semicolons
+func WithSemicolons(mode SemicolonMode) Option {
+	return func(o *Options) {
+		o.Semicolons = mode
+	}
+}
+
+// WithStrict makes problems fail processing instead of being warnings
+func WithStrict(strict bool) Option {
+	return func(o *Options) {
+		o.Strict = strict
+	}
+}
+
+// WithLiterals sets the layout of multi-line list, dict and set literals
+func WithLiterals(style LiteralStyle) Option {
+	return func(o *Options) {
+		o.Literals = style
+	}
+}
+
+// WithTrailingCommas ends canonically laid out literals with a comma
+func WithTrailingCommas(trailingCommas bool) Option {
+	return func(o *Options) {
+		o.TrailingCommas = trailingCommas
+	}
+}
+
+// WithNewline sets the line endings of the output
+func WithNewline(style NewlineStyle) Option {
+	return func(o *Options) {
+		o.Newline = style
+	}
+}
+
+// WithFinalNewline sets whether the output ends with a newline
+func WithFinalNewline(policy FinalNewline) Option {
+	return func(o *Options) {
+		o.FinalNewline = policy
+	}
+}
+
+// WithStripBOM drops a UTF-8 byte order mark from the output
+func WithStripBOM(strip bool) Option {
+	return func(o *Options) {
+		o.StripBOM = strip
+	}
+}
+
+// WithPreserveModTime copies the modification time of inputs to outputs
+func WithPreserveModTime(preserve bool) Option {
+	return func(o *Options) {
+		o.PreserveModTime = preserve
+	}
+}
+
+// WithExtensions enables the given syntax extensions
+func WithExtensions(ext Extension) Option {
+	return func(o *Options) {
+		o.Extensions |= ext
+	}
+}
+
+// WithDefine sets a flag for #!if conditions
+func WithDefine(name, value string) Option {
+	return func(o *Options) {
+		o.Defines = cloneDefines(o.Defines)
+		o.Defines[name] = value
+	}
+}
+
+// WithIncludePath adds a directory searched for #!include files
+func WithIncludePath(dir string) Option {
+	return func(o *Options) {
+		o.IncludePaths = append(o.IncludePaths[:len(o.IncludePaths):len(o.IncludePaths)], dir)
+	}
+}
+
+func newOptions(opts []Option) Options {
+	options := DefaultOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.IndentSize <= 0 {
+		options.IndentSize = DefaultOptions().IndentSize
+	}
+	if options.TabWidth <= 0 {
+		options.TabWidth = DefaultOptions().TabWidth
+	}
+	return options
+}
+
+// config returns the per-file settings the options start every file with
+func (o Options) config() fileConfig {
+	config := fileConfig{
+		indentSize:     o.IndentSize,
+		indentChar:     strings.Repeat(" ", o.IndentSize),
+		tabWidth:       o.TabWidth,
+		semicolons:     o.Semicolons,
+		strict:         o.Strict,
+		literals:       o.Literals,
+		trailingCommas: o.TrailingCommas,
+	}
+	if o.Tabs {
+		config.indentChar = "\t"
+	}
+	return config
+}
+
+func cloneDefines(defines map[string]string) map[string]string {
+	clone := make(map[string]string, len(defines)+1)
+	for name, value := range defines {
+		clone[name] = value
+	}
+	return clone
+}
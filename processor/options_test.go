@@ -0,0 +1,142 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreprocessorWithOptions(t *testing.T) {
+	//given
+	input := `#!if DEBUG
+log("debug");
+#!endif
+if a && b {
+    x = 1; y = 2
+}`
+	expected := "log(\"debug\")\nif a and b:\n\tx = 1\n\ty = 2\n"
+	p := NewPythonPreprocessorWithOptions(
+		WithTabs(true),
+		WithSemicolons(SemicolonsSplit),
+		WithExtensions(ExtLogicalOperators),
+		WithDefine("DEBUG", "1"),
+	)
+
+	//when
+	result, err := p.ProcessString(input)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+	assert.Equal(t, 4, p.IndentSize())
+}
+
+func TestOptionsStrict(t *testing.T) {
+	//given
+	p := NewPythonPreprocessorWithOptions(WithOptions(Options{IndentSize: 2, Strict: true}))
+
+	//when
+	_, err := p.ProcessString("if x {\n    y()\n")
+
+	//then
+	assert.Error(t, err)
+	assert.Equal(t, 2, p.IndentSize())
+}
+
+func TestOptionsDoNotShareState(t *testing.T) {
+	//given
+	base := Options{Defines: map[string]string{"A": "1"}, IncludePaths: make([]string, 1, 4)}
+
+	//when
+	first := newOptions([]Option{WithOptions(base), WithDefine("B", "1"), WithIncludePath("one")})
+	second := newOptions([]Option{WithOptions(base), WithIncludePath("two")})
+
+	//then
+	assert.Equal(t, map[string]string{"A": "1"}, base.Defines)
+	assert.Equal(t, map[string]string{"A": "1", "B": "1"}, first.Defines)
+	assert.Equal(t, []string{"", "one"}, first.IncludePaths)
+	assert.Equal(t, []string{"", "two"}, second.IncludePaths)
+	assert.Equal(t, DefaultOptions().IndentSize, first.IndentSize)
+}
+
+func TestFolderProcessorWithOptions(t *testing.T) {
+	//given
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	writeFiles(t, tmpDir, map[string]string{
+		"input/a.pybrace": "if x {\n    y = true\n}\n",
+	})
+	fp := NewFolderProcessorWithOptions("*.pybrace", 2, WithIndentSize(3), WithExtensions(ExtLiteralAliases))
+
+	//when
+	err := fp.ProcessFolder(inputDir, outputDir)
+
+	//then
+	assert.NoError(t, err)
+	output, err := os.ReadFile(filepath.Join(outputDir, "a.py"))
+	assert.NoError(t, err)
+	assert.Equal(t, "if x:\n   y = True\n", string(output))
+}
+
+func TestTabIndentationWithTabWidth(t *testing.T) {
+	//given
+	input := "config = {\n\t\"a\": {\n\t\t\"b\": 1\n\t}\n}"
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected string
+	}{
+		{"tab output", []Option{WithTabs(true), WithTabWidth(4)},
+			"config = {\n\t\"a\": {\n\t\t\"b\": 1\n\t}\n}\n"},
+		{"space output", []Option{WithTabWidth(4)},
+			"config = {\n    \"a\": {\n        \"b\": 1\n    }\n}\n"},
+		{"default tab width", []Option{WithIndentSize(2)},
+			"config = {\n  \"a\": {\n    \"b\": 1\n  }\n}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPythonPreprocessorWithOptions(tt.opts...)
+
+			//when
+			result, err := p.ProcessString(input)
+
+			//then
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestMixedTabIndentationWithTabWidth(t *testing.T) {
+	//given
+	input := "config = {\n\t\"a\": {\n\t    \"b\": 1\n\t}\n}"
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected string
+	}{
+		{"tab output", []Option{WithTabs(true), WithTabWidth(4)},
+			"config = {\n\t\"a\": {\n\t\t\"b\": 1\n\t}\n}\n"},
+		{"default tab width", []Option{WithIndentSize(2)},
+			"config = {\n  \"a\": {\n    \"b\": 1\n  }\n}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPythonPreprocessorWithOptions(tt.opts...)
+
+			//when
+			result, err := p.ProcessString(input)
+
+			//then
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
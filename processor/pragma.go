@@ -0,0 +1,107 @@
+package processor
+
+import "strings"
+
+const pragmaPrefix = "bython:"
+
+// parsePragma returns the directive text of a "# bython: ..." comment
+func parsePragma(comment string) (string, bool) {
+	if !strings.HasPrefix(comment, "#") {
+		return "", false
+	}
+	body := strings.TrimSpace(comment[1:])
+	if !strings.HasPrefix(body, pragmaPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(body[len(pragmaPrefix):]), true
+}
+
+func isPragma(trimmed, directive string) bool {
+	d, ok := parsePragma(trimmed)
+	return ok && d == directive
+}
+
+// startVerbatimRegion handles "# bython: off"; the lines up to the matching
+// "# bython: on" are emitted untouched apart from their base indentation
+func (p *PythonPreprocessor) startVerbatimRegion(line, trimmed string) []string {
+	p.verbatim = true
+	p.verbatimBase = leadingWidth(line)
+	return []string{p.indent() + trimmed}
+}
+
+// processVerbatimLine re-bases a line of a verbatim region onto the current
+// block. Lines indented less than the region's pragma, such as the contents
+// of multi-line strings, are emitted exactly as written.
+func (p *PythonPreprocessor) processVerbatimLine(line, trimmed string) []string {
+	if isPragma(trimmed, "on") {
+		p.verbatim = false
+		return []string{p.indent() + trimmed}
+	}
+
+	if trimmed == "" {
+		return []string{""}
+	}
+
+	if leadingWidth(line) < p.verbatimBase {
+		return []string{line}
+	}
+	return []string{p.indent() + line[p.verbatimBase:]}
+}
+
+// processOverride handles a line whose trailing "# bython: dict" or
+// "# bython: block" pragma forces how its opening brace is treated. It returns
+// false when the line carries no such pragma.
+func (p *PythonPreprocessor) processOverride(line, trimmed string) ([]string, bool) {
+	code, comment := splitTrailingComment(trimmed)
+	directive, ok := parsePragma(comment)
+	if !ok || (directive != "dict" && directive != "block") {
+		return nil, false
+	}
+
+	classified := p.classifyBlockBrace(code)
+
+	if directive == "dict" {
+		if classified != -1 {
+			p.warn("'# bython: dict' overrides the classifier, which treats the brace at column %d as opening a block", classified+1)
+		}
+		return p.openDict(line, code), true
+	}
+
+	brace := lastOpenBrace(code)
+	switch {
+	case brace == -1:
+		p.warn("'# bython: block' on a line without an opening brace")
+		return []string{p.indent() + p.trimSemicolon(code)}, true
+	case classified == -1:
+		p.warn("'# bython: block' overrides the classifier, which treats this line as an expression")
+	case classified != brace:
+		p.warn("'# bython: block' overrides the classifier, which treats the brace at column %d as opening the block", classified+1)
+	}
+
+	return p.openBlock(strings.TrimSpace(code[:brace]), strings.TrimSpace(code[brace+1:])), true
+}
+
+// classifyBlockBrace returns the index of the brace the heuristics would
+// treat as opening a block, or -1 when the line would not open one
+func (p *PythonPreprocessor) classifyBlockBrace(code string) int {
+	if p.findDictionaryBrace(code) != -1 {
+		return -1
+	}
+	brace := p.findStructuralBrace(code)
+	if brace == -1 || !p.isControlStatement(strings.TrimSpace(code[:brace])) {
+		return -1
+	}
+	return brace
+}
+
+// lastOpenBrace returns the index of the last opening brace outside string
+// literals, or -1 if there is none
+func lastOpenBrace(code string) int {
+	brace := -1
+	for _, tok := range tokenize(code) {
+		if tok.kind == tokenOp && tok.text == "{" {
+			brace = tok.col
+		}
+	}
+	return brace
+}
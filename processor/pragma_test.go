@@ -0,0 +1,125 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerbatimRegion(t *testing.T) {
+	//given
+	input := `def render() {
+    # bython: off
+    template = """
+{name} {
+  body;
+}
+"""
+    if ready: go()
+    # bython: on
+    return template;
+}`
+
+	expected := `def render():
+  # bython: off
+  template = """
+{name} {
+  body;
+}
+"""
+  if ready: go()
+  # bython: on
+  return template
+`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+}
+
+func TestVerbatimRegionKeepsRelativeIndentation(t *testing.T) {
+	//given
+	input := `class Generated {
+        # bython: off
+        def method(self):
+            return {"a": 1}
+
+        x = 1;
+        # bython:on
+}
+print("done");`
+
+	expected := `class Generated:
+    # bython: off
+    def method(self):
+        return {"a": 1}
+
+    x = 1;
+    # bython:on
+print("done")
+`
+
+	p := NewPythonPreprocessor(4)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+}
+
+func TestBlockPragmaOverridesClassifier(t *testing.T) {
+	//given
+	input := `if value in {1, 2} {  # bython: block
+    print(value);
+}`
+
+	expected := `if value in {1, 2}:
+  print(value)
+`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Equal(t, []Diagnostic{{
+		Line:    1,
+		Message: "'# bython: block' overrides the classifier, which treats the brace at column 13 as opening the block",
+	}}, p.Diagnostics())
+}
+
+func TestDictPragmaOverridesClassifier(t *testing.T) {
+	//given
+	input := `defaults = sys.modules["__main__"].__dict__.get("DEFAULTS") or {  # bython: dict
+  "debug": False,
+};
+print(defaults);`
+
+	expected := `defaults = sys.modules["__main__"].__dict__.get("DEFAULTS") or {
+  "debug": False,
+}
+print(defaults)
+`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Len(t, p.Diagnostics(), 1)
+	assert.Equal(t, 1, p.Diagnostics()[0].Line)
+	assert.Contains(t, p.Diagnostics()[0].Message, "'# bython: dict' overrides the classifier")
+}
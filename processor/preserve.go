@@ -0,0 +1,38 @@
+package processor
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// keepsVerbatim reports whether line is a shebang or coding declaration at
+// the top of the input, which must stay exactly as written for the kernel
+// and for Python to find them
+func (p *PythonPreprocessor) keepsVerbatim(line string) bool {
+	if len(p.sources) != 1 || p.lineNumber > 2 || !p.inHeader || !p.compiled() {
+		return false
+	}
+	if p.lineNumber == 1 && strings.HasPrefix(line, directivePrefix) {
+		_, _, directive := parseDirective(line)
+		return !directive
+	}
+	return codingCookie.MatchString(line)
+}
+
+// copyFileInfo gives outputPath the permissions of inputPath, and its
+// modification time when that is preserved. The owner keeps write access so
+// the output can be regenerated.
+func (p *PythonPreprocessor) copyFileInfo(inputPath, outputPath string) error {
+	info, err := os.Stat(inputPath)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(outputPath, info.Mode().Perm()|0200); err != nil {
+		return err
+	}
+	if p.preserveModTime {
+		return os.Chtimes(outputPath, time.Time{}, info.ModTime())
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShebangAndCodingKeptVerbatim(t *testing.T) {
+	//given
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"shebang and coding",
+			"#!/usr/bin/env  python3 -O  \n# -*- coding: utf-8 -*-  \nif x {\n    y()\n}\n",
+			"#!/usr/bin/env  python3 -O  \n# -*- coding: utf-8 -*-  \nif x:\n    y()\n"},
+		{"coding first",
+			"  # vim: set fileencoding=utf-8 :\nx = 1\n",
+			"  # vim: set fileencoding=utf-8 :\nx = 1\n"},
+		{"coding after code",
+			"x = 1\n  # coding: utf-8  \n",
+			"x = 1\n# coding: utf-8\n"},
+		{"directive is not a shebang",
+			"#!if 1\n# coding: utf-8  \n#!endif\n",
+			"# coding: utf-8  \n"},
+		{"shebang with bom",
+			"\ufeff#!/usr/bin/python  \nx = 1\n",
+			"\ufeff#!/usr/bin/python  \nx = 1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPythonPreprocessor(4)
+
+			//when
+			result, err := p.ProcessString(tt.input)
+
+			//then
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+			assert.Empty(t, p.Diagnostics())
+			assert.Empty(t, p.Verify(tt.input, result))
+		})
+	}
+}
+
+func TestProcessFileCopiesFileInfo(t *testing.T) {
+	//given
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "script.by")
+	outputPath := filepath.Join(dir, "script.py")
+	writeFiles(t, dir, map[string]string{"script.by": "#!/usr/bin/env python3\nif x {\n    y()\n}\n"})
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(t, os.Chmod(inputPath, 0750))
+	assert.NoError(t, os.Chtimes(inputPath, modTime, modTime))
+
+	tests := []struct {
+		name     string
+		preserve bool
+	}{
+		{"mode only", false},
+		{"mode and mtime", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPythonPreprocessorWithOptions(WithPreserveModTime(tt.preserve))
+
+			//when
+			err := p.ProcessFile(inputPath, outputPath)
+
+			//then
+			assert.NoError(t, err)
+			info, err := os.Stat(outputPath)
+			assert.NoError(t, err)
+			assert.Equal(t, os.FileMode(0750), info.Mode().Perm())
+			assert.Equal(t, tt.preserve, info.ModTime().Equal(modTime))
+		})
+	}
+}
+
+func TestFolderProcessorCopiesFileInfo(t *testing.T) {
+	//given
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	writeFiles(t, tmpDir, map[string]string{
+		"input/brace.py":    "#!/usr/bin/env python3\nif x {\n    y()\n}\n",
+		"input/standard.py": "#!/usr/bin/env python3\nif x:\n    y()\n",
+		"input/readonly.py": "x = 1\n",
+	})
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	modes := map[string]os.FileMode{"brace.py": 0755, "standard.py": 0700, "readonly.py": 0444}
+	for name, mode := range modes {
+		path := filepath.Join(inputDir, name)
+		assert.NoError(t, os.Chmod(path, mode))
+		assert.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	fp := NewFolderProcessorWithOptions("*.py", 2, WithPreserveModTime(true))
+
+	//when
+	err := fp.ProcessFolder(inputDir, outputDir)
+
+	//then
+	assert.NoError(t, err)
+	expected := map[string]os.FileMode{"brace.py": 0755, "standard.py": 0700, "readonly.py": 0644}
+	for name, mode := range expected {
+		info, err := os.Stat(filepath.Join(outputDir, name))
+		assert.NoError(t, err)
+		assert.Equal(t, mode, info.Mode().Perm(), name)
+		assert.True(t, info.ModTime().Equal(modTime), name)
+	}
+}
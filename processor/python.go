@@ -9,22 +9,154 @@ import (
 )
 
 type PythonPreprocessor struct {
-	indentSize       int
-	indentChar       string
+	fileConfig
+	defaults         fileConfig
+	inHeader         bool
 	indentLevel      int
 	structuralBlocks int
 	dictDepth        int
 	dictBaseIndent   int
+	dictUnit         int
+	newlineStyle     NewlineStyle
+	finalNewline     FinalNewline
+	stripBOM         bool
+	preserveModTime  bool
+	format           lineFormat
+	literal          []string
+	literalDepth     int
+	replaying        bool
+	extensions       Extension
+	flags            map[string]string
+	macros           map[string]macro
+	names            map[string]bool
+	conditionals     []conditional
+	includePaths     []string
+	includes         []string
+	sources          []sourceFrame
+	hybridIndents    []int
+	hybridPending    bool
+	hybridBlocks     int
+	hybridOuter      []hybridFrame
+	loops            []loopFrame
+	doCount          int
+	switches         []switchFrame
+	switchCount      int
+	pending          []string
+	verbatim         bool
+	verbatimBase     int
+	openQuote        string
+	inString         bool
+	inBlockComment   bool
+	file             string
+	lineNumber       int
+	diagnostics      []Diagnostic
 }
 
-func NewPythonPreprocessor(indentSize int) Processor {
-	return &PythonPreprocessor{
-		indentSize:       indentSize,
-		indentChar:       strings.Repeat(" ", indentSize),
+func NewPythonPreprocessor(indentSize int) *PythonPreprocessor {
+	return NewPythonPreprocessorWithOptions(WithIndentSize(indentSize))
+}
+
+// NewPythonPreprocessorWithOptions creates a preprocessor from DefaultOptions
+// changed by opts
+func NewPythonPreprocessorWithOptions(opts ...Option) *PythonPreprocessor {
+	options := newOptions(opts)
+	config := options.config()
+	p := &PythonPreprocessor{
+		fileConfig:       config,
+		defaults:         config,
+		inHeader:         true,
 		indentLevel:      0,
 		structuralBlocks: 0,
 		dictDepth:        0,
+		extensions:       options.Extensions,
+		includePaths:     append([]string(nil), options.IncludePaths...),
+		newlineStyle:     options.Newline,
+		finalNewline:     options.FinalNewline,
+		stripBOM:         options.StripBOM,
+		preserveModTime:  options.PreserveModTime,
 	}
+	for name, value := range options.Defines {
+		p.Define(name, value)
+	}
+	return p
+}
+
+// EnableExtensions turns on the given opt-in syntax extensions
+func (p *PythonPreprocessor) EnableExtensions(ext Extension) {
+	p.extensions |= ext
+}
+
+func (p *PythonPreprocessor) reset() {
+	p.fileConfig = p.defaults
+	p.inHeader = true
+	p.indentLevel = 0
+	p.structuralBlocks = 0
+	p.dictDepth = 0
+	p.dictBaseIndent = 0
+	p.dictUnit = 0
+	p.format = lineFormat{}
+	p.literal = nil
+	p.literalDepth = 0
+	p.replaying = false
+	p.hybridIndents = p.hybridIndents[:0]
+	p.hybridPending = false
+	p.hybridBlocks = 0
+	p.hybridOuter = p.hybridOuter[:0]
+	p.loops = p.loops[:0]
+	p.doCount = 0
+	p.switches = p.switches[:0]
+	p.switchCount = 0
+	p.conditionals = p.conditionals[:0]
+	p.macros = nil
+	p.names = nil
+	p.includes = nil
+	p.file = ""
+	p.pending = p.pending[:0]
+	p.verbatim = false
+	p.verbatimBase = 0
+	p.openQuote = ""
+	p.inString = false
+	p.inBlockComment = false
+	p.lineNumber = 0
+	p.diagnostics = nil
+}
+
+// Diagnostics returns the diagnostics reported while processing the last input
+func (p *PythonPreprocessor) Diagnostics() []Diagnostic {
+	return p.diagnostics
+}
+
+// checkBalanced reports blocks and literals still open at the end of input
+func (p *PythonPreprocessor) checkBalanced() {
+	if p.structuralBlocks > 0 {
+		p.warn("%d block(s) not closed at end of input", p.structuralBlocks)
+	}
+	if p.dictDepth > 0 {
+		p.warn("literal not closed at end of input")
+	}
+	if p.verbatim {
+		p.warn("'# bython: off' region not closed at end of input")
+	}
+	if len(p.conditionals) > 0 {
+		p.warn("'#!if' not closed at end of input")
+	}
+}
+
+// strictError turns the diagnostics into an error when strict mode is on
+func (p *PythonPreprocessor) strictError() error {
+	messages := make([]string, len(p.diagnostics))
+	for i, d := range p.diagnostics {
+		messages[i] = d.String()
+	}
+	return fmt.Errorf("strict mode: %d problem(s):\n%s", len(messages), strings.Join(messages, "\n"))
+}
+
+func (p *PythonPreprocessor) warn(format string, args ...any) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		File:    p.file,
+		Line:    p.lineNumber,
+		Message: fmt.Sprintf(format, args...),
+	})
 }
 
 var controlKeywords = []string{
@@ -32,11 +164,35 @@ var controlKeywords = []string{
 }
 
 func (p *PythonPreprocessor) processLine(line string) []string {
+	if p.literal != nil {
+		return p.collectLiteral(line)
+	}
 	trimmed := strings.TrimSpace(line)
+	if p.verbatim {
+		return p.processVerbatimLine(line, trimmed)
+	}
+	if isPragma(trimmed, "off") {
+		return p.startVerbatimRegion(line, trimmed)
+	}
+
 	if trimmed == "" {
 		return []string{""}
 	}
 
+	if p.inHeader {
+		if !strings.HasPrefix(trimmed, "#") {
+			p.inHeader = false
+		} else if directive, ok := parsePragma(trimmed); ok && isConfigDirective(directive) {
+			p.applyConfigDirective(directive)
+		}
+	}
+
+	if len(p.hybridIndents) > 0 && p.structuralBlocks == p.hybridBlocks {
+		if lines, ok := p.processHybridLine(line, trimmed); ok {
+			return lines
+		}
+	}
+
 	if strings.HasPrefix(trimmed, "#") {
 		return []string{p.indent() + trimmed}
 	}
@@ -44,112 +200,208 @@ func (p *PythonPreprocessor) processLine(line string) []string {
 	if strings.HasPrefix(trimmed, "}") {
 		if p.dictDepth > 0 {
 			p.dictDepth--
-			leadingSpaces := len(line) - len(strings.TrimLeft(line, " \t"))
-			relativeIndent := leadingSpaces - p.dictBaseIndent
-			indentLevels := relativeIndent / p.indentSize
-			if relativeIndent > 0 && indentLevels == 0 {
-				indentLevels = 1
-			}
-			normalizedIndent := strings.Repeat(p.indentChar, indentLevels)
-			processedLine := normalizedIndent + strings.TrimSuffix(trimmed, ";")
+			processedLine := p.literalIndent(line) + p.trimSemicolon(trimmed)
 			if p.dictDepth == 0 {
 				p.dictBaseIndent = 0
+				p.dictUnit = 0
 			}
 			return []string{processedLine}
 		}
 
 		if p.structuralBlocks > 0 {
+			if n := len(p.switches); n > 0 {
+				frame := &p.switches[n-1]
+				switch {
+				case frame.depth == p.structuralBlocks:
+					// the switch itself never indented
+					p.structuralBlocks--
+					p.switches = p.switches[:n-1]
+					if remaining := strings.TrimSpace(trimmed[1:]); remaining != "" {
+						return p.processLine(remaining)
+					}
+					return []string{}
+				case frame.depth+1 == p.structuralBlocks && frame.inCase:
+					p.closeCase(frame)
+				}
+			}
+			if n := len(p.loops); n > 0 && p.loops[n-1].depth == p.structuralBlocks {
+				if p.loops[n-1].do {
+					return p.closeDoLoop(trimmed, nil)
+				}
+				p.loops = p.loops[:n-1]
+			}
 			p.indentLevel--
 			p.structuralBlocks--
 
-			remaining := strings.TrimSpace(trimmed[1:])
-
-			if strings.HasPrefix(remaining, "else") || strings.HasPrefix(remaining, "elif") {
-				return p.processLine(remaining)
-			} else if remaining != "" {
-				return p.processLine(remaining)
+			// what follows the brace keeps the line's indentation, which a
+			// colon-indented block around it is tracked by
+			if remaining := strings.TrimSpace(trimmed[1:]); remaining != "" {
+				return p.processLine(line[:leadingWidth(line)] + remaining)
 			}
 			return []string{}
 		}
 
-		processedLine := strings.TrimSuffix(trimmed, ";")
+		p.warn("unmatched closing brace")
+		processedLine := p.trimSemicolon(trimmed)
 		return []string{p.indent() + processedLine}
 	}
 
 	if p.dictDepth > 0 {
-		leadingSpaces := len(line) - len(strings.TrimLeft(line, " \t"))
-		relativeIndent := leadingSpaces - p.dictBaseIndent
-		indentLevels := relativeIndent / p.indentSize
-		if relativeIndent > 0 && indentLevels == 0 {
-			indentLevels = 1
-		}
-		normalizedIndent := strings.Repeat(p.indentChar, indentLevels)
-		processedLine := normalizedIndent + strings.TrimSuffix(trimmed, ";")
+		processedLine := p.literalIndent(line) + p.trimSemicolon(trimmed)
 		openBraces := strings.Count(processedLine, "{")
 		closeBraces := strings.Count(processedLine, "}")
 		p.dictDepth += openBraces - closeBraces
 		return []string{processedLine}
 	}
 
-	dictBraceIndex := p.findDictionaryBrace(trimmed)
+	trimmed = translateElseIf(trimmed)
+
+	if lines, ok := p.processOverride(line, trimmed); ok {
+		return lines
+	}
+
+	if lines, ok := p.processCase(trimmed); ok {
+		return lines
+	}
+
+	if lines, ok := p.startLiteral(line, trimmed); ok {
+		return lines
+	}
+
+	// braces in a trailing comment neither open a literal nor a block
+	code, comment := splitTrailingComment(trimmed)
+	code = strings.TrimSpace(code)
+
+	dictBraceIndex := p.findDictionaryBrace(code)
 	if dictBraceIndex != -1 {
-		p.dictBaseIndent = len(line) - len(strings.TrimLeft(line, " \t"))
-		p.dictDepth = 1
-		openBraces := strings.Count(trimmed, "{")
-		closeBraces := strings.Count(trimmed, "}")
-		p.dictDepth = openBraces - closeBraces
-		processedLine := p.indent() + strings.TrimSuffix(trimmed, ";")
-		return []string{processedLine}
+		return p.openDict(line, trimmed)
 	}
 
-	needsColon := false
-	openBraceIndex := p.findStructuralBrace(trimmed)
+	if lines, ok := p.openDoLoop(trimmed); ok {
+		return lines
+	}
+
+	if lines, ok := p.openSwitch(trimmed); ok {
+		return lines
+	}
+
+	if p.extensions&ExtHybrid != 0 && p.isHybridHeader(trimmed) {
+		return p.startHybridBlock(line, trimmed)
+	}
+
+	openBraceIndex := p.findStructuralBrace(code)
 
 	if openBraceIndex != -1 {
-		beforeBrace := strings.TrimSpace(trimmed[:openBraceIndex])
-		afterBrace := strings.TrimSpace(trimmed[openBraceIndex+1:])
+		beforeBrace := strings.TrimSpace(code[:openBraceIndex])
+		afterBrace := strings.TrimSpace(code[openBraceIndex+1:])
 
 		if !p.isControlStatement(beforeBrace) {
-			processedLine := strings.TrimSuffix(trimmed, ";")
+			processedLine := p.trimSemicolon(trimmed)
 			return []string{p.indent() + processedLine}
 		}
 
-		if p.isControlStatement(beforeBrace) {
-			needsColon = true
+		// the comment stays on the header, after the colon
+		result := p.openBlock(beforeBrace, afterBrace)
+		if comment != "" {
+			result[0] += "  " + comment
 		}
+		return result
+	}
 
-		processedLine := beforeBrace
-		if needsColon && !strings.HasSuffix(processedLine, ":") {
-			processedLine += ":"
-		}
+	processedLine := trimmed
+	processedLine = p.trimSemicolon(processedLine)
 
-		result := []string{p.indent() + processedLine}
+	return []string{p.indent() + p.markBreak(p.markContinue(processedLine))}
+}
 
-		p.indentLevel++
-		p.structuralBlocks++
+// openDict emits the first line of a dictionary or set literal and enters
+// dict mode while the literal stays open
+func (p *PythonPreprocessor) openDict(line, trimmed string) []string {
+	p.dictBaseIndent = p.indentColumns(line)
+	p.dictUnit = 0
+	code, _ := splitTrailingComment(trimmed)
+	openBraces := strings.Count(code, "{")
+	closeBraces := strings.Count(code, "}")
+	p.dictDepth = max(openBraces-closeBraces, 0)
+	processedLine := p.indent() + p.trimSemicolon(trimmed)
+	return []string{processedLine}
+}
 
-		if afterBrace != "" && afterBrace != "}" {
-			if strings.HasSuffix(afterBrace, "}") {
-				content := strings.TrimSpace(afterBrace[:len(afterBrace)-1])
-				if content != "" {
-					content = strings.TrimSuffix(content, ";")
-					result = append(result, p.indent()+content)
-				}
-				p.indentLevel--
-				p.structuralBlocks--
-			} else {
-				afterBrace = strings.TrimSuffix(afterBrace, ";")
-				result = append(result, p.indent()+afterBrace)
+// literalIndent returns the indentation of a line inside a multi-line
+// literal. Its nesting below the line that opened the literal is counted in
+// the first indentation step the literal uses in the source, rounding steps
+// that are not a multiple of it, so the source's indent width does not have
+// to match the output's.
+func (p *PythonPreprocessor) literalIndent(line string) string {
+	relative := p.indentColumns(line) - p.dictBaseIndent
+	if relative <= 0 {
+		return p.indent()
+	}
+	if p.dictUnit == 0 {
+		p.dictUnit = relative
+	}
+	levels := max((relative+p.dictUnit/2)/p.dictUnit, 1)
+	return p.indent() + strings.Repeat(p.indentChar, levels)
+}
+
+// openBlock emits a block header with its colon and indents what follows;
+// content after the brace on the same line becomes the first body line
+func (p *PythonPreprocessor) openBlock(beforeBrace, afterBrace string) []string {
+	processedLine := beforeBrace
+	if !strings.HasSuffix(processedLine, ":") {
+		processedLine += ":"
+	}
+
+	result := []string{p.indent() + processedLine}
+
+	p.indentLevel++
+	p.structuralBlocks++
+	loop := isLoopHeader(beforeBrace)
+	if loop {
+		p.loops = append(p.loops, loopFrame{depth: p.structuralBlocks})
+	}
+
+	if afterBrace != "" && afterBrace != "}" {
+		if strings.HasSuffix(afterBrace, "}") {
+			result = append(result, p.blockBody(afterBrace[:len(afterBrace)-1])...)
+			p.indentLevel--
+			p.structuralBlocks--
+			if loop {
+				p.loops = p.loops[:len(p.loops)-1]
 			}
+		} else {
+			result = append(result, p.blockBody(afterBrace)...)
 		}
-
-		return result
 	}
 
-	processedLine := trimmed
-	processedLine = strings.TrimSuffix(processedLine, ";")
+	return result
+}
 
-	return []string{p.indent() + processedLine}
+// blockBody returns the lines of statements written on a block header after
+// its opening brace
+func (p *PythonPreprocessor) blockBody(content string) []string {
+	var lines []string
+	for _, statement := range p.splitStatements(strings.TrimSpace(content)) {
+		if statement = p.trimSemicolon(strings.TrimSpace(statement)); statement != "" {
+			lines = append(lines, p.indent()+p.markContinue(statement))
+		}
+	}
+	return lines
+}
+
+// translateElseIf turns a C-style "else if" header into "elif"
+func translateElseIf(trimmed string) string {
+	if !strings.HasPrefix(trimmed, "else") {
+		return trimmed
+	}
+	rest := strings.TrimLeft(trimmed[len("else"):], " \t")
+	if len(rest) == len(trimmed)-len("else") || !strings.HasPrefix(rest, "if") {
+		return trimmed
+	}
+	if len(rest) > 2 && isNameChar(rest[2]) {
+		return trimmed
+	}
+	return "elif " + strings.TrimLeft(rest[2:], " \t")
 }
 
 func (p *PythonPreprocessor) isControlStatement(line string) bool {
@@ -283,48 +535,132 @@ func (p *PythonPreprocessor) isDictionaryBrace(line string, braceIndex int) bool
 
 func (p *PythonPreprocessor) ProcessReader(reader io.Reader, writer io.Writer) error {
 	scanner := bufio.NewScanner(reader)
+	scanner.Split(p.scanLines)
 	first := true
+	written := false
+
+	// each line is terminated when the next is written, since the ending of
+	// the last one depends on the final newline policy
+	write := func(line string) error {
+		prefix := p.newline(p.format)
+		if !written && p.format.bom && !p.stripBOM {
+			prefix = byteOrderMark
+		} else if !written {
+			prefix = ""
+		}
+		written = true
+		_, err := io.WriteString(writer, prefix+line)
+		return err
+	}
 
-	for scanner.Scan() {
-		lines := p.processLine(scanner.Text())
-		for _, line := range lines {
+	// output is held back while a do loop or switch is open, since lines
+	// are inserted into it once they close
+	flush := func() error {
+		for _, line := range p.pending {
 			if line != "" || !first {
-				_, err := fmt.Fprintln(writer, line)
-				if err != nil {
+				if err := write(line); err != nil {
 					return err
 				}
 			}
 			first = false
 		}
+		p.pending = p.pending[:0]
+		return nil
 	}
 
-	return scanner.Err()
+	p.sources = append(p.sources[:0], sourceFrame{scanner: scanner})
+	defer p.closeIncludes()
+
+	for {
+		line, ok, err := p.nextLine()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		if len(p.sources) == 1 && p.lineNumber == 1 {
+			line, p.format.bom = strings.CutPrefix(line, byteOrderMark)
+		}
+		if p.keepsVerbatim(line) {
+			p.pending = append(p.pending, line)
+			if err := flush(); err != nil {
+				return err
+			}
+			continue
+		}
+		if p.skipDirective(line) {
+			continue
+		}
+		p.inString = p.openQuote != ""
+		text := p.rewriteLine(line)
+		for _, statement := range p.splitStatements(text) {
+			p.pending = append(p.pending, p.processLine(statement)...)
+			p.inString = false
+			if !p.holdOutput() {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if p.literal != nil {
+		p.pending = append(p.pending, p.replayLiteral()...)
+	}
+	for _, frame := range p.loops {
+		if frame.do {
+			p.pending = expandContinues(p.pending, frame.id, "")
+		}
+	}
+	for i := range p.switches {
+		p.closeCase(&p.switches[i])
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if written && p.endsWithNewline(p.format) {
+		if _, err := io.WriteString(writer, p.newline(p.format)); err != nil {
+			return err
+		}
+	}
+
+	p.checkBalanced()
+	if p.strict && len(p.diagnostics) > 0 {
+		return p.strictError()
+	}
+	return nil
 }
 
 func (p *PythonPreprocessor) ProcessFile(inputPath, outputPath string) error {
-	p.indentLevel = 0
-	p.structuralBlocks = 0
-	p.dictDepth = 0
+	p.reset()
+	p.file = inputPath
 
-	inputFile, err := os.Open(inputPath)
+	source, err := os.ReadFile(inputPath)
 	if err != nil {
 		return fmt.Errorf("error opening input file: %v", err)
 	}
-	defer func() { _ = inputFile.Close() }()
 
-	outputFile, err := os.Create(outputPath)
-	if err != nil {
+	// the output is written in the encoding the input declares
+	text, encoding := p.decodeSource(source)
+	var output strings.Builder
+	processErr := p.ProcessReader(strings.NewReader(text), &output)
+	reported := len(p.diagnostics)
+	encoded := p.encodeOutput(output.String(), encoding)
+	if p.strict && len(p.diagnostics) > reported {
+		processErr = p.strictError()
+	}
+	if err := os.WriteFile(outputPath, encoded, 0o644); err != nil {
 		return fmt.Errorf("error creating output file: %v", err)
 	}
-	defer func() { _ = outputFile.Close() }()
-
-	return p.ProcessReader(inputFile, outputFile)
+	if err := p.copyFileInfo(inputPath, outputPath); err != nil {
+		return fmt.Errorf("error copying file mode: %v", err)
+	}
+	return processErr
 }
 
 func (p *PythonPreprocessor) ProcessString(input string) (string, error) {
-	p.indentLevel = 0
-	p.structuralBlocks = 0
-	p.dictDepth = 0
+	p.reset()
 	reader := strings.NewReader(input)
 	var builder strings.Builder
 	builder.Grow(len(input) + len(input)/4)
@@ -554,6 +554,69 @@ func TestMultilineDictionary(t *testing.T) {
 	assert.Equal(t, expected, result)
 }
 
+func TestMultilineDictionaryIndentWidth(t *testing.T) {
+	//given
+	input := `def load() {
+    config = {
+        "name": "test",
+        "nested": {
+            "key": "value"
+        }
+    };
+    return config;
+}`
+
+	expected := `def load():
+  config = {
+    "name": "test",
+    "nested": {
+      "key": "value"
+    }
+  }
+  return config
+`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Verify(input, result))
+}
+
+func TestMultilineDictionaryUnevenIndent(t *testing.T) {
+	//given
+	input := `config = {
+    "a": {
+         "b": 1,
+      "c": 2
+    },
+  "d": 3
+}`
+
+	expected := `config = {
+  "a": {
+    "b": 1,
+    "c": 2
+  },
+  "d": 3
+}
+`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Verify(input, result))
+}
+
 func TestSetLiterals(t *testing.T) {
 	//given
 	input := `my_set = {1, 2, 3, 4};
@@ -755,6 +818,46 @@ for hour in range(0,23):
 	assert.Equal(t, expected, result)
 }
 
+func TestBraceLineComments(t *testing.T) {
+	//given
+	input := `for item in items {  # each item
+    if item.failed { # retry once
+        retry(item)
+    } else {  # {not a brace}
+        done(item)
+    }  # end if
+    do {  # at least once
+        poll()
+    } while (busy());  # until idle
+} # end loop
+if ready { go() }  # fast path`
+
+	expected := `for item in items:  # each item
+  if item.failed:  # retry once
+    retry(item)
+  else:  # {not a brace}
+    done(item)
+  # end if
+  while True:  # at least once
+    poll()
+    if not (busy()): break  # until idle
+# end loop
+if ready:  # fast path
+  go()
+`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Diagnostics())
+	assert.Empty(t, p.Verify(input, result))
+}
+
 func TestSetOfSets(t *testing.T) {
 	//given
 	input := `def top() {
@@ -783,3 +886,39 @@ top()
 	//then
 	assert.Equal(t, expected, result)
 }
+
+func TestElseIf(t *testing.T) {
+	//given
+	input := `if x < 0 {
+    print("negative");
+} else if x == 0 {
+    print("zero");
+}
+else if(x < 10) {
+    print("small");
+} else {
+    elsewhere = x;
+    else_if = 1;
+}`
+
+	expected := `if x < 0:
+  print("negative")
+elif x == 0:
+  print("zero")
+elif (x < 10):
+  print("small")
+else:
+  elsewhere = x
+  else_if = 1
+`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, Verify(input, result))
+}
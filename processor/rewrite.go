@@ -0,0 +1,165 @@
+package processor
+
+import (
+	"sort"
+	"strings"
+)
+
+// edit replaces src[start:end] with text; insertions have start == end
+type edit struct {
+	start, end int
+	text       string
+}
+
+// applyEdits applies non-overlapping edits to s; edits at the same offset
+// are applied in the order given
+func applyEdits(s string, edits []edit) string {
+	if len(edits) == 0 {
+		return s
+	}
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	var b strings.Builder
+	b.Grow(len(s) + len(edits)*4)
+	last := 0
+	for _, e := range edits {
+		b.WriteString(s[last:e.start])
+		b.WriteString(e.text)
+		last = e.end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// rewriter rewrites a single line of code; only code outside string
+// literals and comments may be changed
+type rewriter func(p *PythonPreprocessor, code string) string
+
+// rewriters run in order on every line when their extension is enabled,
+// after C comments are converted and macros expanded, so that neither of
+// those is seen as code
+var rewriters = []struct {
+	ext     Extension
+	rewrite rewriter
+}{
+	{ExtLogicalOperators, (*PythonPreprocessor).rewriteLogicalOperators},
+	{ExtLiteralAliases | ExtNilAlias, (*PythonPreprocessor).rewriteLiteralAliases},
+	{ExtTernary, (*PythonPreprocessor).rewriteTernaries},
+	{ExtIncrement, (*PythonPreprocessor).rewriteIncrements},
+}
+
+// rewriteLine expands macros and applies the enabled token-level syntax
+// extensions to a line, leaving the inside of multi-line strings and verbatim
+// regions untouched
+func (p *PythonPreprocessor) rewriteLine(line string) string {
+	prefix, code := "", line
+	if p.openQuote != "" {
+		end := strings.Index(line, p.openQuote)
+		if end == -1 {
+			return line
+		}
+		end += len(p.openQuote)
+		prefix, code = line[:end], line[end:]
+		p.openQuote = ""
+	}
+
+	if !p.verbatim {
+		// macros never expand inside comments
+		if p.extensions&ExtCComments != 0 {
+			code = p.rewriteCComments(code)
+		}
+		if len(p.macros) > 0 {
+			code = p.expandMacros(code)
+		}
+		for _, r := range rewriters {
+			if p.extensions&r.ext != 0 {
+				code = r.rewrite(p, code)
+			}
+		}
+	}
+
+	p.openQuote = unterminatedTripleQuote(code)
+	return prefix + code
+}
+
+// Verify is like the package level Verify but first applies conditional
+// compilation, includes, macros and the enabled syntax extensions to source,
+// so only layout changes are checked. Includes are resolved relative to the file
+// last processed, and divergences inside an included file are reported with
+// its name and line.
+func (p *PythonPreprocessor) Verify(source, output string) []Diagnostic {
+	// problems in the source were already reported when it was processed
+	source, origins, _ := p.expandSource(p.file, strings.TrimPrefix(source, byteOrderMark))
+
+	diagnostics := verify(source, output, p.extensions&ExtHybrid != 0)
+	for i, d := range diagnostics {
+		if d.Line < 1 || d.Line > len(origins) {
+			continue
+		}
+		origin := origins[d.Line-1]
+		diagnostics[i].Line = origin.line
+		if origin.file != p.file {
+			diagnostics[i].File = origin.file
+		}
+	}
+	return diagnostics
+}
+
+func rewriteExtensions() Extension {
+	ext := ExtCComments
+	for _, r := range rewriters {
+		ext |= r.ext
+	}
+	return ext
+}
+
+// unterminatedTripleQuote returns the delimiter of a triple-quoted string
+// left open at the end of code, or "" if there is none
+func unterminatedTripleQuote(code string) string {
+	if !strings.Contains(code, `"""`) && !strings.Contains(code, `'''`) {
+		return ""
+	}
+	tokens := tokenize(code)
+	for i := len(tokens) - 1; i >= 0; i-- {
+		tok := tokens[i]
+		if tok.kind == tokenComment || tok.kind == tokenNewline {
+			continue
+		}
+		if tok.kind != tokenString {
+			return ""
+		}
+		body := strings.TrimLeft(tok.text, "rRuUfFbBtT")
+		for _, delimiter := range []string{`"""`, `'''`} {
+			if strings.HasPrefix(body, delimiter) &&
+				(len(body) < 2*len(delimiter) || !strings.HasSuffix(body, delimiter)) {
+				return delimiter
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+// adjacent reports whether tokens a and b touch with no whitespace between
+func adjacent(a, b token) bool {
+	return a.line == b.line && a.col+len(a.text) == b.col
+}
+
+// isOpPair reports whether tokens[i] and tokens[i+1] spell op twice, as in
+// && or ||, which the tokenizer splits into single characters
+func isOpPair(tokens []token, i int, op string) bool {
+	return i+1 < len(tokens) && tokens[i].kind == tokenOp && tokens[i].text == op &&
+		tokens[i+1].kind == tokenOp && tokens[i+1].text == op && adjacent(tokens[i], tokens[i+1])
+}
+
+// spaced pads word with spaces where code does not already separate it from
+// its neighbours at start and end
+func spaced(code string, start, end int, word string) string {
+	if start > 0 && code[start-1] != ' ' && code[start-1] != '\t' && code[start-1] != '(' && code[start-1] != '[' {
+		word = " " + word
+	}
+	if end < len(code) && code[end] != ' ' && code[end] != '\t' {
+		word += " "
+	}
+	return word
+}
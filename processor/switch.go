@@ -0,0 +1,248 @@
+package processor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// switchFrame tracks an open switch statement. Its braces do not indent:
+// the cases become an if/elif/else chain at the level of the switch.
+type switchFrame struct {
+	depth      int
+	temp       string
+	cases      int
+	sawDefault bool
+	inCase     bool
+	marker     string
+}
+
+// openSwitch handles "switch expr {", which assigns expr to a temporary the
+// cases are compared against. It returns false when the line is not a
+// switch header.
+func (p *PythonPreprocessor) openSwitch(trimmed string) ([]string, bool) {
+	code, comment := splitTrailingComment(trimmed)
+	if !hasKeywordPrefix(code, "switch") || !strings.HasSuffix(code, "{") {
+		return nil, false
+	}
+	expr := strings.TrimSpace(code[len("switch") : len(code)-1])
+	if expr == "" {
+		return nil, false
+	}
+
+	p.switchCount++
+	temp := fmt.Sprintf("_switch_%d", p.switchCount)
+	p.structuralBlocks++
+	p.switches = append(p.switches, switchFrame{depth: p.structuralBlocks, temp: temp})
+
+	line := p.indent() + temp + " = " + expr
+	if comment != "" {
+		line += "  " + comment
+	}
+	return []string{line}, true
+}
+
+// processCase handles a "case A {", "case B, C {" or "default {" line
+// directly inside a switch. It returns false for any other line.
+func (p *PythonPreprocessor) processCase(trimmed string) ([]string, bool) {
+	if len(p.switches) == 0 {
+		return nil, false
+	}
+	frame := &p.switches[len(p.switches)-1]
+	if frame.depth != p.structuralBlocks {
+		return nil, false
+	}
+
+	code, comment := splitTrailingComment(trimmed)
+	isCase := hasKeywordPrefix(code, "case")
+	if !isCase && !hasKeywordPrefix(code, "default") {
+		p.warn("statement inside switch is not part of any case")
+		return nil, false
+	}
+	brace := p.findStructuralBrace(code)
+	if brace == -1 {
+		p.warn("case without a block in switch")
+		return nil, false
+	}
+
+	header := "else"
+	if isCase {
+		values := strings.TrimSpace(code[len("case"):brace])
+		if frame.sawDefault {
+			p.warn("case after default in switch is never reached")
+		}
+		keyword := "elif"
+		if frame.cases == 0 {
+			keyword = "if"
+		}
+		if hasTopLevelComma(significantTokens(values)) {
+			header = keyword + " " + frame.temp + " in (" + values + ")"
+		} else {
+			header = keyword + " " + frame.temp + " == " + values
+		}
+	} else {
+		if frame.cases == 0 {
+			header = "if True"
+		}
+		frame.sawDefault = true
+	}
+	frame.cases++
+
+	afterBrace := strings.TrimSpace(code[brace+1:])
+	var result []string
+	if strings.HasSuffix(afterBrace, "}") && strings.TrimSpace(strings.TrimSuffix(afterBrace, "}")) == "" {
+		result = []string{p.indent() + header + ":", p.indent() + p.indentChar + "pass"}
+	} else {
+		result = p.openBlock(header, afterBrace)
+		frame.inCase = p.structuralBlocks > frame.depth
+		// a body written on the header line is already complete
+		if n := len(result); !frame.inCase && n > 1 {
+			if line, ok := dropCaseBreak(result[n-1]); ok {
+				switch {
+				case line != "":
+					result[n-1] = line
+				case n > 2:
+					result = result[:n-1]
+				default:
+					result[n-1] = p.indent() + p.indentChar + "pass"
+				}
+			}
+		}
+		if frame.inCase && afterBrace == "" {
+			// resolved to pass or dropped once the case closes
+			frame.marker = fmt.Sprintf("\x00case%s.%d\x00", frame.temp, frame.cases)
+			result = append(result, p.indent()+frame.marker)
+		}
+	}
+
+	if comment != "" {
+		result[0] += "  " + comment
+	}
+	return result, true
+}
+
+// closeCase is called at the brace closing a case body; an empty body gets
+// a pass statement
+func (p *PythonPreprocessor) closeCase(frame *switchFrame) {
+	frame.inCase = false
+	p.resolveCaseBreaks(frame)
+	if frame.marker == "" {
+		return
+	}
+	p.resolveEmptyBody(frame.marker)
+	frame.marker = ""
+}
+
+// breakPrefix starts the marker of a break directly inside the current case
+// of frame; the marker ends with the source line of the break
+func breakPrefix(frame *switchFrame) string {
+	return fmt.Sprintf("\x00break%s.%d:", frame.temp, frame.cases)
+}
+
+// markBreak tags a break statement directly inside a case body. C writes it
+// to end the case, but in the if/elif chain it would leave the loop around
+// the switch, so closeCase drops or reports it.
+func (p *PythonPreprocessor) markBreak(stmt string) string {
+	n := len(p.switches)
+	if n == 0 {
+		return stmt
+	}
+	frame := &p.switches[n-1]
+	if code, _ := splitTrailingComment(stmt); !frame.inCase || p.structuralBlocks != frame.depth+1 || code != "break" {
+		return stmt
+	}
+	return breakPrefix(frame) + strconv.Itoa(p.lineNumber) + "\x00" + stmt
+}
+
+// resolveCaseBreaks drops a break ending the body of the case being closed
+// and reports any other break directly inside it
+func (p *PythonPreprocessor) resolveCaseBreaks(frame *switchFrame) {
+	prefix := breakPrefix(frame)
+	last := -1
+	for i, line := range p.pending {
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			last = i
+		}
+	}
+
+	kept := p.pending[:0]
+	for i, line := range p.pending {
+		at := strings.Index(line, prefix)
+		if at == -1 {
+			kept = append(kept, line)
+			continue
+		}
+		if i == last {
+			continue
+		}
+		number := line[at+len(prefix):]
+		number = number[:strings.IndexByte(number, 0)]
+		savedLine := p.lineNumber
+		p.lineNumber, _ = strconv.Atoi(number)
+		p.warn("'break' inside a case leaves the loop around the switch; cases end without one")
+		p.lineNumber = savedLine
+		kept = append(kept, line[:at]+line[at+len(prefix)+len(number)+1:])
+	}
+	p.pending = kept
+}
+
+// dropCaseBreak removes the break ending a case body written on its header
+// line, returning false when there is none
+func dropCaseBreak(line string) (string, bool) {
+	code, comment := splitTrailingComment(strings.TrimSpace(line))
+	if code == "break" {
+		return "", true
+	}
+	before, ok := strings.CutSuffix(code, "break")
+	if trimmed := strings.TrimRight(before, " \t"); !ok || !strings.HasSuffix(trimmed, ";") {
+		return line, false
+	}
+	line = line[:leadingWidth(line)] + strings.TrimRight(strings.TrimSuffix(strings.TrimRight(before, " \t"), ";"), " \t")
+	if comment != "" {
+		line += "  " + comment
+	}
+	return line, true
+}
+
+// resolveEmptyBody removes the marker line, ending the body with pass when
+// only blank lines and comments follow the marker
+func (p *PythonPreprocessor) resolveEmptyBody(marker string) {
+	for i, line := range p.pending {
+		if !strings.Contains(line, marker) {
+			continue
+		}
+		for _, after := range p.pending[i+1:] {
+			if trimmed := strings.TrimSpace(after); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+				p.pending = append(p.pending[:i], p.pending[i+1:]...)
+				return
+			}
+		}
+		indentation := line[:strings.Index(line, marker)]
+		p.pending = append(append(p.pending[:i], p.pending[i+1:]...), indentation+"pass")
+		return
+	}
+}
+
+// hasKeywordPrefix reports whether code starts with keyword as a whole word
+func hasKeywordPrefix(code, keyword string) bool {
+	return strings.HasPrefix(code, keyword) && (len(code) == len(keyword) || !isNameChar(code[len(keyword)]))
+}
+
+// hasTopLevelComma reports whether tokens form a comma separated list
+func hasTopLevelComma(tokens []token) bool {
+	depth := 0
+	for _, tok := range tokens {
+		if tok.kind != tokenOp {
+			continue
+		}
+		switch {
+		case isOpenBracket(tok.text):
+			depth++
+		case isCloseBracket(tok.text):
+			depth--
+		case tok.text == "," && depth == 0:
+			return true
+		}
+	}
+	return false
+}
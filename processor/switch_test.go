@@ -0,0 +1,162 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSwitch(t *testing.T) {
+	//given
+	input := `def describe(cmd) {
+    switch cmd.lower() {
+        case "start" {
+            run();
+        }
+        case "stop", "halt" {
+            # nothing to do yet
+        }
+        case "skip" { pass }
+        default {
+            return "unknown";
+        }
+    }
+    return "ok";
+}`
+
+	expected := `def describe(cmd):
+  _switch_1 = cmd.lower()
+  if _switch_1 == "start":
+    run()
+  elif _switch_1 in ("stop", "halt"):
+    # nothing to do yet
+    pass
+  elif _switch_1 == "skip":
+    pass
+  else:
+    return "unknown"
+  return "ok"
+`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Diagnostics())
+	assert.Empty(t, p.Verify(input, result))
+}
+
+func TestNestedSwitch(t *testing.T) {
+	//given
+	input := `switch a {
+    case 1 {
+        switch b {
+            case 2 { hit() }
+        }
+    } case 3 {
+    }
+}
+done()`
+
+	expected := `_switch_1 = a
+if _switch_1 == 1:
+  _switch_2 = b
+  if _switch_2 == 2:
+    hit()
+elif _switch_1 == 3:
+  pass
+done()
+`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Diagnostics())
+	assert.Empty(t, p.Verify(input, result))
+}
+
+func TestSwitchCaseBreak(t *testing.T) {
+	//given
+	input := `for cmd in commands {
+    switch cmd {
+        case "start" {
+            run();
+            break;
+        }
+        case "stop" { halt(); break }
+        case "skip" { break }
+        case "retry" {
+            if failed() { break }
+            break;
+            log(cmd)
+        }
+        default {
+            break  # nothing else
+            # to do
+        }
+    }
+}`
+
+	expected := `for cmd in commands:
+  _switch_1 = cmd
+  if _switch_1 == "start":
+    run()
+  elif _switch_1 == "stop":
+    halt()
+  elif _switch_1 == "skip":
+    pass
+  elif _switch_1 == "retry":
+    if failed():
+      break
+    break
+    log(cmd)
+  else:
+    # to do
+    pass
+`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Equal(t, []Diagnostic{
+		{Line: 11, Message: "'break' inside a case leaves the loop around the switch; cases end without one"},
+	}, p.Diagnostics())
+	assert.Empty(t, p.Verify(input, result))
+}
+
+func TestSwitchDiagnostics(t *testing.T) {
+	//given
+	input := `switch x {
+    print(x)
+    default { a() }
+    case 1 { b() }
+}`
+
+	p := NewPythonPreprocessor(2)
+
+	//when
+	_, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	diagnostics := p.Diagnostics()
+	assert.Len(t, diagnostics, 2)
+	assert.Equal(t, 2, diagnostics[0].Line)
+	assert.Contains(t, diagnostics[0].Message, "not part of any case")
+	assert.Equal(t, 4, diagnostics[1].Line)
+	assert.Contains(t, diagnostics[1].Message, "never reached")
+}
@@ -0,0 +1,236 @@
+package processor
+
+import "strings"
+
+// ternaryBoundaries end the clause before them, so a conditional expression
+// starting after one of them takes nothing to its left as condition
+var ternaryBoundaries = map[string]bool{
+	"return": true, "yield": true, "if": true, "elif": true, "while": true, "assert": true,
+	"raise": true, "del": true, "await": true,
+}
+
+// ternary rewrites the C-style conditional expressions of a single line
+type ternary struct {
+	p      *PythonPreprocessor
+	code   string
+	tokens []token
+}
+
+// rewriteTernaries translates cond ? a : b into a if cond else b. As in C,
+// ?: binds looser than any other operator and nests to the right, so a
+// nested conditional in the true branch is parenthesised. The line is split
+// at commas, assignments, semicolons and the colons of dicts, slices, lambdas
+// and annotations, and each piece is converted on its own.
+func (p *PythonPreprocessor) rewriteTernaries(code string) string {
+	if !strings.Contains(code, "?") {
+		return code
+	}
+
+	tokens := significantTokens(code)
+	found := false
+	for _, tok := range tokens {
+		if tok.kind == tokenOp && tok.text == "?" {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return code
+	}
+
+	t := ternary{p: p, code: code, tokens: tokens}
+	n := len(tokens)
+	return code[:tokens[0].col] + t.segments(0, n) + code[t.end(n-1):]
+}
+
+// end returns the offset just past tokens[j]
+func (t *ternary) end(j int) int {
+	return t.tokens[j].col + len(t.tokens[j].text)
+}
+
+// segments converts tokens[lo:hi], splitting it at the tokens that cannot be
+// part of a conditional expression
+func (t *ternary) segments(lo, hi int) string {
+	var b strings.Builder
+	last := t.tokens[lo].col
+	start := lo
+	var openers []string
+
+	piece := func(a, z int) {
+		if a >= z {
+			return
+		}
+		b.WriteString(t.code[last:t.tokens[a].col])
+		b.WriteString(t.expr(a, z))
+		last = t.end(z - 1)
+	}
+
+	for j := lo; j < hi; j++ {
+		tok := t.tokens[j]
+		if tok.kind == tokenName {
+			switch tok.text {
+			case "lambda":
+				openers = append(openers, "lambda")
+			case "for":
+				// comprehension clauses are converted separately
+				piece(start, j)
+				start = j
+				openers = openers[:0]
+			}
+			continue
+		}
+		if tok.kind != tokenOp {
+			continue
+		}
+
+		switch {
+		case isOpenBracket(tok.text):
+			if close := closingBracket(t.tokens[:hi], j); close != -1 {
+				j = close
+				continue
+			}
+		case tok.text == "?":
+			openers = append(openers, "?")
+			continue
+		case tok.text == ":" && len(openers) > 0:
+			openers = openers[:len(openers)-1]
+			continue
+		case tok.text == ":", tok.text == ",", tok.text == ";", tok.text == "->", isAssignment(tok.text), isCloseBracket(tok.text):
+		default:
+			continue
+		}
+
+		piece(start, j)
+		start = j + 1
+		openers = openers[:0]
+	}
+	piece(start, hi)
+
+	b.WriteString(t.code[last:t.end(hi-1)])
+	return b.String()
+}
+
+// expr converts a piece of a line that contains at most one conditional
+// expression at its top level, along with whatever clause keyword precedes it
+func (t *ternary) expr(lo, hi int) string {
+	question, condStart := -1, lo
+	forClause := t.tokens[lo].kind == tokenName && t.tokens[lo].text == "for"
+	var lambdas []bool
+
+	for j := lo; j < hi && question == -1; j++ {
+		tok := t.tokens[j]
+		switch {
+		case tok.kind == tokenName && tok.text == "lambda":
+			lambdas = append(lambdas, true)
+		case tok.kind == tokenName && (ternaryBoundaries[tok.text] || (forClause && tok.text == "in")):
+			condStart = j + 1
+		case tok.kind == tokenOp && isOpenBracket(tok.text):
+			if close := closingBracket(t.tokens[:hi], j); close != -1 {
+				j = close
+			}
+		case tok.kind == tokenOp && tok.text == ":" && len(lambdas) > 0:
+			lambdas = lambdas[:len(lambdas)-1]
+			condStart = j + 1
+		case tok.kind == tokenOp && tok.text == "?":
+			question = j
+		}
+	}
+	if question == -1 {
+		return t.plain(lo, hi)
+	}
+
+	colon := t.ternaryColon(question, hi)
+	if colon == -1 || condStart == question || colon == question+1 || colon == hi-1 {
+		t.p.warn("incomplete conditional expression: expected 'cond ? a : b'")
+		return t.plain(lo, hi)
+	}
+
+	whenTrue := t.expr(question+1, colon)
+	if t.tokens[question+1].text == "lambda" || t.hasTernary(question+1, colon) {
+		whenTrue = "(" + whenTrue + ")"
+	}
+	cond := t.plain(condStart, question)
+	whenFalse := t.expr(colon+1, hi)
+
+	var b strings.Builder
+	if condStart > lo {
+		b.WriteString(t.plain(lo, condStart))
+		b.WriteString(t.code[t.end(condStart-1):t.tokens[condStart].col])
+	}
+	b.WriteString(whenTrue + " if " + cond + " else " + whenFalse)
+	return b.String()
+}
+
+// ternaryColon returns the index of the : that pairs with the ? at
+// tokens[question], or -1
+func (t *ternary) ternaryColon(question, hi int) int {
+	depth := 0
+	for j := question + 1; j < hi; j++ {
+		tok := t.tokens[j]
+		if tok.kind == tokenName && tok.text == "lambda" {
+			depth++
+			continue
+		}
+		if tok.kind != tokenOp {
+			continue
+		}
+		switch {
+		case isOpenBracket(tok.text):
+			if close := closingBracket(t.tokens[:hi], j); close != -1 {
+				j = close
+			}
+		case tok.text == "?":
+			depth++
+		case tok.text == ":":
+			if depth == 0 {
+				return j
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// hasTernary reports whether tokens[lo:hi] has a ? outside brackets
+func (t *ternary) hasTernary(lo, hi int) bool {
+	for j := lo; j < hi; j++ {
+		tok := t.tokens[j]
+		if tok.kind != tokenOp {
+			continue
+		}
+		if isOpenBracket(tok.text) {
+			if close := closingBracket(t.tokens[:hi], j); close != -1 {
+				j = close
+			}
+			continue
+		}
+		if tok.text == "?" {
+			return true
+		}
+	}
+	return false
+}
+
+// plain copies tokens[lo:hi], converting only inside the brackets it holds
+func (t *ternary) plain(lo, hi int) string {
+	var b strings.Builder
+	last := t.tokens[lo].col
+	for j := lo; j < hi; j++ {
+		tok := t.tokens[j]
+		if tok.kind != tokenOp || !isOpenBracket(tok.text) {
+			continue
+		}
+		close := closingBracket(t.tokens[:hi], j)
+		if close == -1 {
+			continue
+		}
+		if close > j+1 {
+			b.WriteString(t.code[last:t.tokens[j+1].col])
+			b.WriteString(t.segments(j+1, close))
+			last = t.end(close - 1)
+		}
+		j = close
+	}
+	b.WriteString(t.code[last:t.end(hi-1)])
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTernary(t *testing.T) {
+	//given
+	input := `def sign(x) {
+    label = x > 0 ? "positive" : x < 0 ? "negative" : "zero";
+    return x >= 0 ? 1 : -1;
+}
+size = big ? small ? 2 : 3 : 1
+print(flag ? "yes?" : "no:", end="")  # why? because: reasons
+if n % 2 == 0 ? strict : lenient {
+    pass
+}`
+
+	expected := `def sign(x):
+  label = "positive" if x > 0 else "negative" if x < 0 else "zero"
+  return 1 if x >= 0 else -1
+size = (2 if small else 3) if big else 1
+print("yes?" if flag else "no:", end="")  # why? because: reasons
+if strict if n % 2 == 0 else lenient:
+  pass
+`
+
+	p := NewPythonPreprocessor(2)
+	p.EnableExtensions(ExtTernary)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Diagnostics())
+	assert.Empty(t, p.Verify(input, result))
+}
+
+func TestTernaryColons(t *testing.T) {
+	//given
+	input := `d = {"k": ok ? a : b, key ? 1 : 2: v}
+s = items[first ? 1 : 0 : n]
+f = lambda x: x ? x : default
+g = ok ? lambda: 1 : lambda: 2
+def h(x: int = debug ? 1 : 0) -> int {
+    return [y ? 1 : 0 for y in (lazy ? gen() : xs) if y]
+}`
+
+	expected := `d = {"k": a if ok else b, 1 if key else 2: v}
+s = items[1 if first else 0 : n]
+f = lambda x: x if x else default
+g = (lambda: 1) if ok else lambda: 2
+def h(x: int = 1 if debug else 0) -> int:
+  return [1 if y else 0 for y in (gen() if lazy else xs) if y]
+`
+
+	p := NewPythonPreprocessor(2)
+	p.EnableExtensions(ExtTernary)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, expected, result)
+	assert.Empty(t, p.Diagnostics())
+}
+
+func TestTernaryIncomplete(t *testing.T) {
+	//given
+	input := `x = ready ? go()`
+
+	p := NewPythonPreprocessor(2)
+	p.EnableExtensions(ExtTernary)
+
+	//when
+	result, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//then
+	assert.Equal(t, "x = ready ? go()\n", result)
+	assert.Len(t, p.Diagnostics(), 1)
+	assert.Equal(t, 1, p.Diagnostics()[0].Line)
+}
@@ -0,0 +1,243 @@
+package processor
+
+import "strings"
+
+type tokenKind int
+
+const (
+	tokenName tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenOp
+	tokenComment
+	tokenNewline
+)
+
+// token is a lexical unit of Python source; line is 1-based and col is the
+// byte offset of the token within its line
+type token struct {
+	kind tokenKind
+	text string
+	line int
+	col  int
+}
+
+// operators are ordered longest first so the lexer can take the first match
+var operators = []string{
+	"**=", "//=", ">>=", "<<=", "...",
+	"->", ":=", "==", "!=", "<=", ">=", "**", "//", "<<", ">>",
+	"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "@=",
+}
+
+var stringPrefixes = map[string]bool{
+	"r": true, "u": true, "f": true, "b": true, "t": true,
+	"br": true, "rb": true, "fr": true, "rf": true, "tr": true, "rt": true,
+}
+
+func isNameStart(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch >= 0x80
+}
+
+func isNameChar(ch byte) bool {
+	return isNameStart(ch) || (ch >= '0' && ch <= '9')
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// tokenize splits src into Python tokens. It is deliberately forgiving:
+// unterminated strings end at the end of the line and unknown characters
+// become single character operators, so brace-style input lexes as well.
+func tokenize(src string) []token {
+	var tokens []token
+	line, lineStart := 1, 0
+
+	for i := 0; i < len(src); {
+		ch := src[i]
+
+		switch {
+		case ch == '\n':
+			tokens = append(tokens, token{tokenNewline, "\n", line, i - lineStart})
+			i++
+			line++
+			lineStart = i
+			continue
+		case ch == ' ' || ch == '\t' || ch == '\r' || ch == '\f':
+			i++
+			continue
+		case ch == '\\' && i+1 < len(src) && (src[i+1] == '\n' || src[i+1] == '\r'):
+			// explicit line continuation
+			i++
+			if src[i] == '\r' && i+1 < len(src) && src[i+1] == '\n' {
+				i++
+			}
+			i++
+			line++
+			lineStart = i
+			continue
+		case ch == '#':
+			end := strings.IndexByte(src[i:], '\n')
+			if end == -1 {
+				end = len(src) - i
+			}
+			tokens = append(tokens, token{tokenComment, strings.TrimRight(src[i:i+end], "\r"), line, i - lineStart})
+			i += end
+			continue
+		}
+
+		start := i
+		col := i - lineStart
+
+		if isNameStart(ch) {
+			for i < len(src) && isNameChar(src[i]) {
+				i++
+			}
+			if i < len(src) && (src[i] == '"' || src[i] == '\'') && stringPrefixes[strings.ToLower(src[start:i])] {
+				end, lines, lastStart := scanString(src, i)
+				tokens = append(tokens, token{tokenString, src[start:end], line, col})
+				if lines > 0 {
+					line += lines
+					lineStart = lastStart
+				}
+				i = end
+				continue
+			}
+			tokens = append(tokens, token{tokenName, src[start:i], line, col})
+			continue
+		}
+
+		if isDigit(ch) || (ch == '.' && i+1 < len(src) && isDigit(src[i+1])) {
+			i = scanNumber(src, i)
+			tokens = append(tokens, token{tokenNumber, src[start:i], line, col})
+			continue
+		}
+
+		if ch == '"' || ch == '\'' {
+			end, lines, lastStart := scanString(src, i)
+			tokens = append(tokens, token{tokenString, src[start:end], line, col})
+			if lines > 0 {
+				line += lines
+				lineStart = lastStart
+			}
+			i = end
+			continue
+		}
+
+		op := string(ch)
+		for _, candidate := range operators {
+			if strings.HasPrefix(src[i:], candidate) {
+				op = candidate
+				break
+			}
+		}
+		tokens = append(tokens, token{tokenOp, op, line, col})
+		i += len(op)
+	}
+
+	return tokens
+}
+
+// scanNumber returns the end offset of the numeric literal starting at i
+func scanNumber(src string, i int) int {
+	hex := strings.HasPrefix(src[i:], "0x") || strings.HasPrefix(src[i:], "0X")
+	for i < len(src) {
+		ch := src[i]
+		if isNameChar(ch) || ch == '.' {
+			i++
+			continue
+		}
+		if (ch == '+' || ch == '-') && !hex && (src[i-1] == 'e' || src[i-1] == 'E') {
+			i++
+			continue
+		}
+		break
+	}
+	return i
+}
+
+// scanString returns the end offset of the string literal whose opening quote
+// is at i, together with the number of newlines it spans and the offset of
+// the start of its last line
+func scanString(src string, i int) (end, lines, lastLineStart int) {
+	quote := src[i]
+	delimiter := string(quote)
+	if strings.HasPrefix(src[i:], strings.Repeat(delimiter, 3)) {
+		delimiter = strings.Repeat(delimiter, 3)
+	}
+	triple := len(delimiter) == 3
+
+	j := i + len(delimiter)
+	for j < len(src) {
+		ch := src[j]
+		if ch == '\\' && j+1 < len(src) {
+			if src[j+1] == '\n' {
+				lines++
+				lastLineStart = j + 2
+			}
+			// raw strings cannot end on an escaped quote either
+			j += 2
+			continue
+		}
+		if ch == '\n' {
+			if !triple {
+				return j, lines, lastLineStart
+			}
+			lines++
+			lastLineStart = j + 1
+		}
+		if strings.HasPrefix(src[j:], delimiter) {
+			return j + len(delimiter), lines, lastLineStart
+		}
+		j++
+	}
+	return len(src), lines, lastLineStart
+}
+
+func isOpenBracket(s string) bool {
+	return s == "(" || s == "[" || s == "{"
+}
+
+func isCloseBracket(s string) bool {
+	return s == ")" || s == "]" || s == "}"
+}
+
+// closingBracket returns the index of the bracket closing tokens[open], or
+// -1 when it is not closed within tokens
+func closingBracket(tokens []token, open int) int {
+	depth := 0
+	for k := open; k < len(tokens); k++ {
+		if tokens[k].kind != tokenOp {
+			continue
+		}
+		if isOpenBracket(tokens[k].text) {
+			depth++
+		} else if isCloseBracket(tokens[k].text) {
+			depth--
+			if depth == 0 {
+				return k
+			}
+		}
+	}
+	return -1
+}
+
+// openingBracket returns the index of the bracket opened by the one closing
+// at tokens[close], or -1 when it is not opened within tokens
+func openingBracket(tokens []token, close int) int {
+	depth := 0
+	for k := close; k >= 0; k-- {
+		if tokens[k].kind != tokenOp {
+			continue
+		}
+		if isCloseBracket(tokens[k].text) {
+			depth++
+		} else if isOpenBracket(tokens[k].text) {
+			depth--
+			if depth == 0 {
+				return k
+			}
+		}
+	}
+	return -1
+}
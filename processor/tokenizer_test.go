@@ -0,0 +1,25 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBracketMatching(t *testing.T) {
+	//given
+	tokens := tokenize(`f(a[1], {b: (c)}) + (d`)
+
+	//when
+	closed := closingBracket(tokens, 1)
+	unclosed := closingBracket(tokens, len(tokens)-2)
+	opened := openingBracket(tokens, closed)
+	unopened := openingBracket(tokens[2:closed+1], closed-2)
+
+	//then
+	assert.Equal(t, ")", tokens[closed].text)
+	assert.Equal(t, "+", tokens[closed+1].text)
+	assert.Equal(t, -1, unclosed)
+	assert.Equal(t, 1, opened)
+	assert.Equal(t, -1, unopened)
+}
@@ -0,0 +1,659 @@
+package processor
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// maxVerifyEdits bounds the diff between source and output; beyond it only
+// the first divergence is reported
+const maxVerifyEdits = 1000
+
+// compoundKeywords start statements that may own a block
+var compoundKeywords = map[string]bool{
+	"if": true, "elif": true, "else": true, "while": true, "for": true, "def": true,
+	"class": true, "try": true, "except": true, "finally": true, "with": true, "async": true,
+}
+
+// literalKeywords are followed by an expression, so a brace after them opens
+// a literal rather than a block
+var literalKeywords = map[string]bool{
+	"in": true, "not": true, "and": true, "or": true, "is": true, "if": true, "elif": true,
+	"while": true, "lambda": true, "await": true, "return": true, "yield": true,
+}
+
+// verifyToken is a token that survived normalisation, tagged with the block
+// depth it appears at and the line it came from
+type verifyToken struct {
+	text  string
+	depth int
+	line  int
+}
+
+// Verify checks that output differs from the brace-style source only in
+// layout. Both sides are tokenized; braces, header colons, semicolons,
+// comments, indentation and the trailing commas of lists, dicts and sets are
+// stripped and the remaining tokens are compared together with the block
+// depth each one appears at. Every divergent region is reported as a
+// diagnostic on the source line where it starts.
+func Verify(source, output string) []Diagnostic {
+	return verify(source, output, false)
+}
+
+// verify is Verify, with hybrid accepting the colon-indented blocks of the
+// hybrid extension in source
+func verify(source, output string, hybrid bool) []Diagnostic {
+	a := dropTrailingCommas(braceTokens(strings.TrimPrefix(source, byteOrderMark), hybrid))
+	b := dropTrailingCommas(pythonTokens(strings.TrimPrefix(output, byteOrderMark)))
+
+	equal := func(i, j int) bool {
+		return a[i].text == b[j].text && a[i].depth == b[j].depth
+	}
+
+	ops, ok := diffEdits(len(a), len(b), equal, maxVerifyEdits)
+	if !ok {
+		return firstDivergence(a, b)
+	}
+
+	var diagnostics []Diagnostic
+	var deleted, inserted []verifyToken
+	i, j := 0, 0
+
+	flush := func() {
+		if len(deleted) == 0 && len(inserted) == 0 {
+			return
+		}
+		diagnostics = append(diagnostics, divergence(a, b, i, j, deleted, inserted))
+		deleted, inserted = nil, nil
+	}
+
+	for _, op := range ops {
+		switch op {
+		case editEqual:
+			flush()
+			i++
+			j++
+		case editDelete:
+			deleted = append(deleted, a[i])
+			i++
+		case editInsert:
+			inserted = append(inserted, b[j])
+			j++
+		}
+	}
+	flush()
+
+	return diagnostics
+}
+
+// dropTrailingCommas removes commas directly before the bracket closing a
+// list, dict or set, which do not change its value. Tuples keep theirs.
+func dropTrailingCommas(tokens []verifyToken) []verifyToken {
+	out := tokens[:0]
+	for i, tok := range tokens {
+		if tok.text == "," && i+1 < len(tokens) && (tokens[i+1].text == "]" || tokens[i+1].text == "}") {
+			continue
+		}
+		out = append(out, tok)
+	}
+	return out
+}
+
+// braceTokens normalises brace-style source, tracking block depth from the
+// braces that open blocks after compound statement headers. With hybrid,
+// a header ending with a colon opens a block that lasts while the lines
+// after it are indented deeper than the header.
+func braceTokens(src string, hybrid bool) []verifyToken {
+	var out []verifyToken
+	var stmt []token
+	var braces []braceFrame
+	var closing *doClose
+	var headers []int
+	depth, brackets, switches := 0, 0, 0
+	atLineStart := true
+
+	tokens := tokenize(src)
+	for i, tok := range tokens {
+		if closing != nil {
+			if closing.collect(tok) {
+				continue
+			}
+			out = closing.desugar(out)
+			closing = nil
+			stmt = stmt[:0]
+			if tok.kind == tokenNewline || tok.text == ";" {
+				continue
+			}
+		}
+
+		switch tok.kind {
+		case tokenComment:
+			continue
+		case tokenNewline:
+			if brackets == 0 {
+				stmt = stmt[:0]
+				atLineStart = true
+			}
+			continue
+		}
+
+		// a colon-indented block ends at the first line not indented past
+		// its header
+		if atLineStart {
+			for len(headers) > 0 && tok.col <= headers[len(headers)-1] {
+				headers = headers[:len(headers)-1]
+				depth--
+			}
+			atLineStart = false
+		}
+
+		if tok.kind == tokenOp {
+			switch tok.text {
+			case ";":
+				if brackets == 0 {
+					stmt = stmt[:0]
+				}
+				continue
+			case ":":
+				if hybrid && brackets == 0 && len(stmt) > 0 && stmt[0].kind == tokenName &&
+					compoundKeywords[stmt[0].text] && endsLine(tokens, i+1) {
+					headers = append(headers, stmt[0].col)
+					depth++
+					continue
+				}
+			case "(", "[":
+				brackets++
+			case ")", "]":
+				if brackets > 0 {
+					brackets--
+				}
+			case "{":
+				// a switch does not indent; its cases are lowered to an if/elif chain
+				if n := len(braces); brackets == 0 && n > 0 && braces[n-1].temp != "" && len(stmt) > 0 {
+					var ok bool
+					if out, ok = lowerCase(out, stmt, &braces[n-1]); ok {
+						braces = append(braces, braceFrame{structural: true, isCase: true, bodyStart: len(out)})
+						depth++
+						stmt = stmt[:0]
+						continue
+					}
+				}
+				if brackets == 0 && len(stmt) > 1 && stmt[0].text == "switch" {
+					switches++
+					temp := fmt.Sprintf("_switch_%d", switches)
+					k := len(out) - len(stmt)
+					out[k].text = temp
+					out = slices.Insert(out, k+1, verifyToken{"=", depth, stmt[0].line})
+					braces = append(braces, braceFrame{structural: true, temp: temp})
+					stmt = stmt[:0]
+					continue
+				}
+				if brackets == 0 && opensBlock(stmt) {
+					if n := len(stmt); stmt[n-1].text == ":" {
+						out = out[:len(out)-1]
+					}
+					frame := braceFrame{structural: true, loop: isLoopStatement(stmt)}
+					// "do {" is written as "while True:"
+					if len(stmt) == 1 && stmt[0].text == "do" {
+						out = append(out, verifyToken{"True", depth, tok.line})
+						out[len(out)-2].text = "while"
+						frame.do = true
+					}
+					braces = append(braces, frame)
+					depth++
+					stmt = stmt[:0]
+					continue
+				}
+				braces = append(braces, braceFrame{})
+				brackets++
+			case "}":
+				if n := len(braces); n > 0 {
+					frame := braces[n-1]
+					braces = braces[:n-1]
+					if frame.structural {
+						if frame.temp != "" {
+							stmt = stmt[:0]
+							continue
+						}
+						// a break ending a case body is dropped, and an empty
+						// case body is given a pass statement
+						if frame.isCase && frame.breakAt == len(out) {
+							out = out[:len(out)-1]
+						}
+						if frame.isCase && len(out) == frame.bodyStart {
+							out = append(out, verifyToken{"pass", depth, tok.line})
+						}
+						if frame.do {
+							closing = &doClose{frame: frame, depth: depth, line: tok.line}
+						}
+						depth--
+						stmt = stmt[:0]
+						continue
+					}
+					brackets--
+				}
+			}
+		}
+
+		// a do loop's continue statements are preceded by its condition check
+		if tok.kind == tokenName && tok.text == "continue" && len(stmt) == 0 {
+			if frame := innermostLoop(braces); frame != nil && frame.do {
+				frame.continues = append(frame.continues, len(out))
+			}
+		}
+
+		if tok.kind == tokenName && tok.text == "break" && len(stmt) == 0 {
+			if n := len(braces); n > 0 && braces[n-1].isCase {
+				braces[n-1].breakAt = len(out) + 1
+			}
+		}
+
+		// "else if" headers are written as elif
+		if tok.kind == tokenName && tok.text == "if" && len(stmt) == 1 && stmt[0].text == "else" {
+			stmt[0].text = "elif"
+			out[len(out)-1].text = "elif"
+			continue
+		}
+
+		stmt = append(stmt, tok)
+		out = append(out, verifyToken{tok.text, depth, tok.line})
+	}
+
+	return out
+}
+
+// braceFrame is an open brace seen by braceTokens; continues holds the
+// output positions of the continue statements of a do loop, temp the
+// temporary a switch is lowered to and breakAt the output length after the
+// last break directly inside a case body
+type braceFrame struct {
+	structural bool
+	loop       bool
+	do         bool
+	continues  []int
+	temp       string
+	cases      int
+	isCase     bool
+	bodyStart  int
+	breakAt    int
+}
+
+// lowerCase replaces the tokens of a case or default header at the end of
+// out with those of the if/elif/else header the converter writes for it
+func lowerCase(out []verifyToken, stmt []token, sw *braceFrame) ([]verifyToken, bool) {
+	k := len(out) - len(stmt)
+	at := func(text string) verifyToken {
+		return verifyToken{text, out[k].depth, stmt[0].line}
+	}
+
+	var header []verifyToken
+	switch {
+	case stmt[0].text == "default" && len(stmt) == 1:
+		header = []verifyToken{at("else")}
+		if sw.cases == 0 {
+			header = []verifyToken{at("if"), at("True")}
+		}
+	case stmt[0].text == "case" && len(stmt) > 1:
+		keyword := "elif"
+		if sw.cases == 0 {
+			keyword = "if"
+		}
+		header = []verifyToken{at(keyword), at(sw.temp)}
+		if hasTopLevelComma(stmt[1:]) {
+			header = append(header, at("in"), at("("))
+			header = append(header, out[k+1:]...)
+			header = append(header, at(")"))
+		} else {
+			header = append(header, at("=="))
+			header = append(header, out[k+1:]...)
+		}
+	default:
+		return out, false
+	}
+
+	sw.cases++
+	return append(out[:k], header...), true
+}
+
+func isLoopStatement(stmt []token) bool {
+	switch stmt[0].text {
+	case "for", "while", "do":
+		return true
+	case "async":
+		return len(stmt) > 1 && stmt[1].text == "for"
+	}
+	return false
+}
+
+// innermostLoop returns the innermost open loop block, or nil
+func innermostLoop(braces []braceFrame) *braceFrame {
+	for i := len(braces) - 1; i >= 0; i-- {
+		if braces[i].loop {
+			return &braces[i]
+		}
+	}
+	return nil
+}
+
+// doClose collects the "while cond;" that follows the brace closing a do
+// loop at depth
+type doClose struct {
+	frame    braceFrame
+	depth    int
+	line     int
+	cond     []token
+	brackets int
+	started  bool
+}
+
+// collect takes the next token of the condition, returning false at the
+// token that ends it
+func (c *doClose) collect(tok token) bool {
+	if tok.kind == tokenComment {
+		return true
+	}
+	if !c.started {
+		c.started = true
+		return tok.kind == tokenName && tok.text == "while"
+	}
+	if c.brackets == 0 && (tok.kind == tokenNewline || tok.text == ";") {
+		return false
+	}
+	switch {
+	case tok.kind == tokenNewline:
+		return true
+	case tok.kind == tokenOp && isOpenBracket(tok.text):
+		c.brackets++
+	case tok.kind == tokenOp && isCloseBracket(tok.text):
+		c.brackets--
+	}
+	c.cond = append(c.cond, tok)
+	return true
+}
+
+// desugar adds the condition check the converter writes at the end of the
+// loop body and before each of its continue statements
+func (c *doClose) desugar(out []verifyToken) []verifyToken {
+	texts := []string{"break"}
+	cond := c.cond
+	if len(cond) > 0 {
+		if cond[0].text == "(" && closingBracket(cond, 0) == len(cond)-1 && cond[len(cond)-1].text == ")" {
+			cond = cond[1 : len(cond)-1]
+		}
+		texts = []string{"if", "not", "("}
+		for _, tok := range cond {
+			texts = append(texts, tok.text)
+		}
+		texts = append(texts, ")", ":", "break")
+	}
+
+	check := func(depth, line int) []verifyToken {
+		tokens := make([]verifyToken, len(texts))
+		for k, text := range texts {
+			tokens[k] = verifyToken{text, depth, line}
+		}
+		return tokens
+	}
+
+	out = append(out, check(c.depth, c.line)...)
+	for k := len(c.frame.continues) - 1; k >= 0; k-- {
+		at := c.frame.continues[k]
+		out = append(out[:at], append(check(out[at].depth, out[at].line), out[at:]...)...)
+	}
+	return out
+}
+
+// opensBlock reports whether a brace following stmt opens a block
+func opensBlock(stmt []token) bool {
+	if len(stmt) == 1 && stmt[0].text == "do" {
+		return true
+	}
+	if len(stmt) == 0 || stmt[0].kind != tokenName || !compoundKeywords[stmt[0].text] {
+		return false
+	}
+
+	prev := stmt[len(stmt)-1]
+	if prev.text == ":" && len(stmt) > 1 {
+		prev = stmt[len(stmt)-2]
+	}
+
+	switch prev.kind {
+	case tokenName:
+		return !literalKeywords[prev.text]
+	case tokenNumber, tokenString:
+		return true
+	}
+	return prev.text == ")" || prev.text == "]" || prev.text == "}"
+}
+
+// pythonTokens normalises standard Python, tracking block depth from
+// indentation and dropping the colons that end block headers
+func pythonTokens(src string) []verifyToken {
+	var out []verifyToken
+	var stmt []token
+	indents := []int{0}
+	brackets := 0
+	atLineStart := true
+
+	tokens := tokenize(src)
+	for i, tok := range tokens {
+		switch tok.kind {
+		case tokenComment:
+			continue
+		case tokenNewline:
+			if brackets == 0 {
+				atLineStart = true
+				stmt = stmt[:0]
+			}
+			continue
+		}
+
+		if atLineStart {
+			for tok.col < indents[len(indents)-1] {
+				indents = indents[:len(indents)-1]
+			}
+			if tok.col > indents[len(indents)-1] {
+				indents = append(indents, tok.col)
+			}
+			atLineStart = false
+		}
+
+		if tok.kind == tokenOp {
+			switch tok.text {
+			case "(", "[", "{":
+				brackets++
+			case ")", "]", "}":
+				if brackets > 0 {
+					brackets--
+				}
+			case ";":
+				stmt = stmt[:0]
+				continue
+			case ":":
+				if brackets == 0 && len(stmt) > 0 && stmt[0].kind == tokenName &&
+					compoundKeywords[stmt[0].text] && endsLine(tokens, i+1) {
+					continue
+				}
+			}
+		}
+
+		stmt = append(stmt, tok)
+		out = append(out, verifyToken{tok.text, len(indents) - 1, tok.line})
+	}
+
+	return out
+}
+
+// endsLine reports whether only comments remain before the next newline
+func endsLine(tokens []token, from int) bool {
+	for _, tok := range tokens[from:] {
+		switch tok.kind {
+		case tokenNewline:
+			return true
+		case tokenComment:
+			continue
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func divergence(a, b []verifyToken, i, j int, deleted, inserted []verifyToken) Diagnostic {
+	line := lineAt(a, i, deleted)
+	outLine := lineAt(b, j, inserted)
+
+	if len(deleted) == len(inserted) {
+		sameText := true
+		for k := range deleted {
+			if deleted[k].text != inserted[k].text {
+				sameText = false
+				break
+			}
+		}
+		if sameText {
+			return Diagnostic{
+				Line: line,
+				Message: fmt.Sprintf("block nesting differs at output line %d: depth %d in source, %d in output",
+					outLine, deleted[0].depth, inserted[0].depth),
+			}
+		}
+	}
+
+	return Diagnostic{
+		Line: line,
+		Message: fmt.Sprintf("output line %d diverges from source: expected %s, got %s",
+			outLine, describeTokens(deleted), describeTokens(inserted)),
+	}
+}
+
+// lineAt returns the line of the first token in run, falling back to the
+// token at position i of the whole stream
+func lineAt(stream []verifyToken, i int, run []verifyToken) int {
+	if len(run) > 0 {
+		return run[0].line
+	}
+	if i < len(stream) {
+		return stream[i].line
+	}
+	if len(stream) > 0 {
+		return stream[len(stream)-1].line
+	}
+	return 0
+}
+
+func describeTokens(tokens []verifyToken) string {
+	if len(tokens) == 0 {
+		return "nothing"
+	}
+	const limit = 8
+	texts := make([]string, 0, limit)
+	for k, tok := range tokens {
+		if k == limit {
+			texts = append(texts, "...")
+			break
+		}
+		texts = append(texts, tok.text)
+	}
+	return fmt.Sprintf("%q", strings.Join(texts, " "))
+}
+
+func firstDivergence(a, b []verifyToken) []Diagnostic {
+	i := 0
+	for i < len(a) && i < len(b) && a[i].text == b[i].text && a[i].depth == b[i].depth {
+		i++
+	}
+	var deleted, inserted []verifyToken
+	if i < len(a) {
+		deleted = a[i : i+1]
+	}
+	if i < len(b) {
+		inserted = b[i : i+1]
+	}
+	return []Diagnostic{divergence(a, b, i, i, deleted, inserted)}
+}
+
+type editOp int
+
+const (
+	editEqual editOp = iota
+	editDelete
+	editInsert
+)
+
+// diffEdits computes a shortest edit script turning a sequence of length n
+// into one of length m using Myers' algorithm. It gives up and returns false
+// once more than maxEdits edits would be needed.
+func diffEdits(n, m int, equal func(i, j int) bool, maxEdits int) ([]editOp, bool) {
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+
+	for d := 0; d <= n+m && d <= maxEdits; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && equal(x, y) {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+				return backtrackEdits(trace, n, m), true
+			}
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+	}
+
+	return nil, false
+}
+
+// backtrackEdits walks the saved frontiers of diffEdits back from (n, m);
+// trace[d] holds the furthest x for diagonals -d..d
+func backtrackEdits(trace [][]int, n, m int) []editOp {
+	var ops []editOp
+	x, y := n, m
+
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		at := func(k int) int { return prev[k+d-1] }
+
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, editEqual)
+			x--
+			y--
+		}
+		if x == prevX {
+			ops = append(ops, editInsert)
+		} else {
+			ops = append(ops, editDelete)
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, editEqual)
+		x--
+		y--
+	}
+
+	for l, r := 0, len(ops)-1; l < r; l, r = l+1, r-1 {
+		ops[l], ops[r] = ops[r], ops[l]
+	}
+	return ops
+}
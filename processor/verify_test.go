@@ -0,0 +1,102 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyConvertedOutput(t *testing.T) {
+	//given
+	input := `class Point {
+    def __init__(self, x, y) {
+        self.x = x; self.y = y;
+        self.tags = {"a", "b"};
+    }
+}
+
+if x in (1, 2) {
+    print(f"{x}");
+} elif x == 3 {
+    data = {
+        "key": [1, 2]
+    };
+} else {
+    pass;
+}`
+
+	p := NewPythonPreprocessor(2)
+	output, err := p.ProcessString(input)
+	assert.NoError(t, err)
+
+	//when
+	diagnostics := Verify(input, output)
+
+	//then
+	assert.Empty(t, diagnostics)
+}
+
+func TestVerifyFlagsChangedTokens(t *testing.T) {
+	//given
+	input := `if x {
+    print("a");
+    y = 1;
+}`
+
+	output := `if x:
+  print("a")
+  y = 2
+`
+
+	//when
+	diagnostics := Verify(input, output)
+
+	//then
+	assert.Len(t, diagnostics, 1)
+	assert.Equal(t, 3, diagnostics[0].Line)
+	assert.Contains(t, diagnostics[0].Message, `expected "1", got "2"`)
+}
+
+func TestVerifyFlagsNestingDifference(t *testing.T) {
+	//given
+	input := `for i in items {
+    if i {
+        a();
+    }
+    b();
+}`
+
+	output := `for i in items:
+  if i:
+    a()
+    b()
+`
+
+	//when
+	diagnostics := Verify(input, output)
+
+	//then
+	assert.Len(t, diagnostics, 1)
+	assert.Equal(t, 5, diagnostics[0].Line)
+	assert.Contains(t, diagnostics[0].Message, "block nesting differs at output line 4")
+}
+
+func TestVerifyFlagsMissingTokens(t *testing.T) {
+	//given
+	input := `def f() {
+    return 1;
+}
+g();`
+
+	output := `def f():
+  return 1
+`
+
+	//when
+	diagnostics := Verify(input, output)
+
+	//then
+	assert.Len(t, diagnostics, 1)
+	assert.Equal(t, 4, diagnostics[0].Line)
+	assert.Contains(t, diagnostics[0].Message, `expected "g ( )", got nothing`)
+}